@@ -0,0 +1,223 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// benchcheck compares benchmark results against a stored baseline and flags regressions.
+//
+// It reads the output of 'go test -bench' from stdin:
+//
+//	go test -run '^$' -bench . -benchmem -count 5 ./... | go run ./benchcheck -baseline benchmarks/baseline.txt
+//
+// With -update, the results are stored as new baseline instead. The baseline uses the format of
+// 'go test -bench', so it can also be used with other tools like benchstat.
+// Baselines depend on the machine, so they should only be compared to results from the same machine.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// benchLineRegexp matches a result line of 'go test -bench'. The GOMAXPROCS suffix is not part of the name.
+var benchLineRegexp = regexp.MustCompile(`\A(?P<name>Benchmark\S*?)(-\d+)?\s+\d+\s+(?P<metrics>.*)\z`)
+
+// results maps a benchmark ("package.Name") to its metrics ("ns/op") and all measured values.
+type results map[string]map[string][]float64
+
+// parseResults reads the output of 'go test -bench'. All lines not containing results are ignored.
+func parseResults(r io.Reader) (results, error) {
+	res := make(results)
+	pkg := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "pkg: ") {
+			pkg = strings.TrimPrefix(line, "pkg: ")
+			continue
+		}
+		m := benchLineRegexp.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		name := m[benchLineRegexp.SubexpIndex("name")]
+		if pkg != "" {
+			name = pkg + "." + name
+		}
+		fields := strings.Fields(m[benchLineRegexp.SubexpIndex("metrics")])
+		if len(fields)%2 != 0 {
+			return nil, fmt.Errorf("malformed benchmark line: %s", line)
+		}
+		if res[name] == nil {
+			res[name] = make(map[string][]float64)
+		}
+		for i := 0; i < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("malformed value '%s' in line: %s", fields[i], line)
+			}
+			res[name][fields[i+1]] = append(res[name][fields[i+1]], v)
+		}
+	}
+	return res, scanner.Err()
+}
+
+// median returns the median of values. values must not be empty.
+func median(values []float64) float64 {
+	v := append([]float64(nil), values...)
+	sort.Float64s(v)
+	if len(v)%2 == 1 {
+		return v[len(v)/2]
+	}
+	return (v[len(v)/2-1] + v[len(v)/2]) / 2
+}
+
+// comparison is the comparison of a single metric of a benchmark.
+type comparison struct {
+	name, metric      string
+	baseline, current float64
+	// change is relative to baseline, positive values are slower or use more memory
+	change     float64
+	regression bool
+}
+
+// compare compares the medians of all metrics present in baseline and current.
+// A change of a metric listed in checked is a regression if it exceeds threshold.
+// It also returns all benchmarks only present in one of the results.
+func compare(baseline, current results, checked map[string]bool, threshold float64) ([]comparison, []string, []string) {
+	var comparisons []comparison
+	var added, missing []string
+	for name := range current {
+		if _, ok := baseline[name]; !ok {
+			added = append(added, name)
+			continue
+		}
+		for metric, values := range current[name] {
+			base, ok := baseline[name][metric]
+			if !ok {
+				continue
+			}
+			c := comparison{name: name, metric: metric, baseline: median(base), current: median(values)}
+			switch {
+			case c.baseline != 0:
+				c.change = (c.current - c.baseline) / c.baseline
+			case c.current != 0:
+				c.change = 1
+			}
+			c.regression = checked[metric] && c.change > threshold
+			comparisons = append(comparisons, c)
+		}
+	}
+	for name := range baseline {
+		if _, ok := current[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	sort.Slice(comparisons, func(i, j int) bool {
+		if comparisons[i].name != comparisons[j].name {
+			return comparisons[i].name < comparisons[j].name
+		}
+		return comparisons[i].metric < comparisons[j].metric
+	})
+	sort.Strings(added)
+	sort.Strings(missing)
+	return comparisons, added, missing
+}
+
+func main() {
+	baselinePath := flag.String("baseline", "benchmarks/baseline.txt", "path to baseline file")
+	update := flag.Bool("update", false, "store the results as new baseline instead of comparing")
+	threshold := flag.Float64("threshold", 0.2, "relative change of a metric which is reported as regression")
+	metrics := flag.String("metrics", "ns/op,allocs/op", "comma separated list of metrics checked for regressions")
+	flag.Parse()
+
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "can not read benchmark results:", err)
+		os.Exit(2)
+	}
+	current, err := parseResults(bytes.NewReader(input))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(current) == 0 {
+		fmt.Fprintln(os.Stderr, "no benchmark results found")
+		os.Exit(2)
+	}
+
+	if *update {
+		err = os.MkdirAll(filepath.Dir(*baselinePath), 0755)
+		if err == nil {
+			err = os.WriteFile(*baselinePath, input, 0644)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "can not write baseline:", err)
+			os.Exit(2)
+		}
+		fmt.Printf("Stored %d benchmarks in %s\n", len(current), *baselinePath)
+		return
+	}
+
+	f, err := os.Open(*baselinePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "can not read baseline:", err)
+		os.Exit(2)
+	}
+	baseline, err := parseResults(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "can not read baseline:", err)
+		os.Exit(2)
+	}
+
+	checked := make(map[string]bool)
+	for _, m := range strings.Split(*metrics, ",") {
+		checked[strings.TrimSpace(m)] = true
+	}
+	comparisons, added, missing := compare(baseline, current, checked, *threshold)
+
+	regressions := 0
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "benchmark\tmetric\tbaseline\tcurrent\tchange\t")
+	for _, c := range comparisons {
+		mark := ""
+		if c.regression {
+			mark = "REGRESSION"
+			regressions++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.6g\t%.6g\t%+.1f%%\t%s\n", c.name, c.metric, c.baseline, c.current, c.change*100, mark)
+	}
+	tw.Flush()
+	for _, name := range added {
+		fmt.Println("not in baseline:", name)
+	}
+	for _, name := range missing {
+		fmt.Println("missing in results:", name)
+	}
+
+	if regressions != 0 {
+		fmt.Printf("%d regressions (threshold %.1f%%)\n", regressions, *threshold*100)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const baselineOutput = `goos: linux
+goarch: amd64
+pkg: example.com/sim
+BenchmarkRun/stations=10-8     	    5000	    200000 ns/op	   1000 B/op	      10 allocs/op
+BenchmarkRun/stations=10-8     	    5000	    220000 ns/op	   1000 B/op	      10 allocs/op
+BenchmarkRun/stations=10-8     	    5000	    210000 ns/op	   1000 B/op	      10 allocs/op
+BenchmarkParse-8               	     100	      5000 ns/op
+BenchmarkRemoved               	     100	      5000 ns/op
+PASS
+ok  	example.com/sim	3.000s
+`
+
+const currentOutput = `pkg: example.com/sim
+BenchmarkRun/stations=10-4     	    5000	    205000 ns/op	   3000 B/op	      10 allocs/op
+BenchmarkParse-4               	     100	      6000 ns/op
+BenchmarkNew-4                 	     100	      6000 ns/op
+`
+
+func TestCompare(t *testing.T) {
+	baseline, err := parseResults(strings.NewReader(baselineOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := parseResults(strings.NewReader(currentOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := baseline["example.com/sim.BenchmarkRun/stations=10"]["ns/op"]; len(got) != 3 {
+		t.Fatalf("wrong parsed values: %v", got)
+	}
+
+	comparisons, added, missing := compare(baseline, current, map[string]bool{"ns/op": true, "allocs/op": true}, 0.1)
+	var regressions []string
+	for _, c := range comparisons {
+		if c.regression {
+			regressions = append(regressions, c.name+" "+c.metric)
+		}
+	}
+	// B/op is not checked, Run is within the threshold of the median
+	if len(regressions) != 1 || regressions[0] != "example.com/sim.BenchmarkParse ns/op" {
+		t.Errorf("wrong regressions: %v", regressions)
+	}
+	if len(comparisons) != 4 {
+		t.Errorf("wrong number of comparisons: %d", len(comparisons))
+	}
+	if len(added) != 1 || added[0] != "example.com/sim.BenchmarkNew" {
+		t.Errorf("wrong added benchmarks: %v", added)
+	}
+	if len(missing) != 1 || missing[0] != "example.com/sim.BenchmarkRemoved" {
+		t.Errorf("wrong missing benchmarks: %v", missing)
+	}
+}
@@ -0,0 +1,466 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/informatiCup/informatiCup2022/Bahn-Simulator/simulator"
+)
+
+const debugHelp = `Commands:
+  step [N]                  simulate the next N timesteps (default 1)
+  run [until T]             simulate until the end, a breakpoint, an error or timestep T
+  back [N]                  go back N timesteps (default 1)
+  break KIND ID             stop 'run' when the entity changes (KIND is train, passenger, station or line)
+  break                     list all breakpoints
+  delete [KIND ID]          delete a breakpoint or all breakpoints
+  show [KIND] ID            show the state of an entity
+  show                      show the state of the simulation
+  errors                    show all errors found so far
+  help                      show this help
+  quit                      exit the debugger`
+
+// debugKinds contains the entity kinds in the order used to find entities by ID only.
+var debugKinds = []string{"train", "passenger", "station", "line"}
+
+func debugCommand(args []string) int {
+	fs := flag.NewFlagSet("debug", flag.ExitOnError)
+	inputPath := fs.String("input", "input.txt", "path to input file")
+	outputPath := fs.String("output", "output.txt", "path to output file")
+	history := fs.Int("history", 1000, "number of timesteps which can be stepped back")
+	keepGoing := fs.Bool("keep-going", false, "continue after rule violations")
+	fs.Parse(args)
+
+	world, err := readWorld(*inputPath, *outputPath, nil)
+	if err != nil {
+		var perr simulator.ParseErrors
+		if errors.As(err, &perr) {
+			for i := range perr {
+				fmt.Fprintln(os.Stderr, perr[i].Error())
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, "Can not read input file:", err)
+		}
+		return 2
+	}
+
+	d := newDebugger(world, simulator.Options{TickByTick: true, KeepGoing: *keepGoing}, *history, os.Stdout)
+	d.repl(os.Stdin)
+	return 0
+}
+
+// debugger steps through a simulation interactively.
+// Every timestep is simulated, so the debugger can stop at all of them.
+type debugger struct {
+	s   *simulator.Simulation
+	w   *simulator.World
+	out io.Writer
+
+	// history contains checkpoints before the last steps, the newest last
+	history    []*simulator.Checkpoint
+	maxHistory int
+	// breaks contains breakpoints as "kind id"
+	breaks map[string]bool
+}
+
+func newDebugger(w *simulator.World, opt simulator.Options, history int, out io.Writer) *debugger {
+	d := &debugger{
+		w:          w,
+		out:        out,
+		maxHistory: history,
+		breaks:     make(map[string]bool),
+	}
+	d.s = w.NewSimulation(opt)
+	d.printErrors(0)
+	return d
+}
+
+// repl reads commands from in until it is closed or 'quit' is read.
+func (d *debugger) repl(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprintf(d.out, "(%s) ", d.w.CurrentTime.String())
+		if !scanner.Scan() {
+			fmt.Fprintln(d.out)
+			return
+		}
+		if !d.exec(scanner.Text()) {
+			return
+		}
+	}
+}
+
+// exec executes a single command. It returns false if the debugger should exit.
+func (d *debugger) exec(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+	args := fields[1:]
+	switch fields[0] {
+	case "step", "s":
+		n, ok := d.count(args)
+		if !ok {
+			return true
+		}
+		for i := 0; i < n && d.step(); i++ {
+		}
+		d.status()
+	case "run", "r":
+		var until *simulator.Int
+		if len(args) != 0 {
+			if len(args) != 2 || args[0] != "until" {
+				fmt.Fprintln(d.out, "usage: run [until T]")
+				return true
+			}
+			t, ok := new(simulator.Int).SetString(args[1], 10)
+			if !ok {
+				fmt.Fprintf(d.out, "invalid timestep '%s'\n", args[1])
+				return true
+			}
+			until = t
+		}
+		d.run(until)
+		d.status()
+	case "back", "b":
+		n, ok := d.count(args)
+		if !ok {
+			return true
+		}
+		if len(d.history) == 0 {
+			fmt.Fprintln(d.out, "no earlier timestep available")
+			return true
+		}
+		if n > len(d.history) {
+			n = len(d.history)
+		}
+		c := d.history[len(d.history)-n]
+		d.history = d.history[:len(d.history)-n]
+		if err := d.s.Restore(c); err != nil {
+			fmt.Fprintln(d.out, "can not go back:", err)
+			return true
+		}
+		d.status()
+	case "break":
+		switch len(args) {
+		case 0:
+			d.listBreaks()
+		case 2:
+			if !d.exists(args[0], args[1]) {
+				fmt.Fprintf(d.out, "unknown %s '%s'\n", args[0], args[1])
+				return true
+			}
+			d.breaks[args[0]+" "+args[1]] = true
+		default:
+			fmt.Fprintln(d.out, "usage: break KIND ID")
+		}
+	case "delete":
+		switch len(args) {
+		case 0:
+			d.breaks = make(map[string]bool)
+		case 2:
+			delete(d.breaks, args[0]+" "+args[1])
+		default:
+			fmt.Fprintln(d.out, "usage: delete [KIND ID]")
+		}
+	case "show":
+		switch len(args) {
+		case 0:
+			d.status()
+		case 1:
+			for _, kind := range debugKinds {
+				if d.exists(kind, args[0]) {
+					fmt.Fprint(d.out, d.describe(kind, args[0]))
+					return true
+				}
+			}
+			fmt.Fprintf(d.out, "unknown entity '%s'\n", args[0])
+		case 2:
+			if !d.exists(args[0], args[1]) {
+				fmt.Fprintf(d.out, "unknown %s '%s'\n", args[0], args[1])
+				return true
+			}
+			fmt.Fprint(d.out, d.describe(args[0], args[1]))
+		default:
+			fmt.Fprintln(d.out, "usage: show [KIND] ID")
+		}
+	case "errors":
+		d.printErrors(0)
+	case "help", "h":
+		fmt.Fprintln(d.out, debugHelp)
+	case "quit", "q", "exit":
+		return false
+	default:
+		fmt.Fprintf(d.out, "unknown command '%s', try 'help'\n", fields[0])
+	}
+	return true
+}
+
+// count parses the optional count argument of a command.
+func (d *debugger) count(args []string) (int, bool) {
+	if len(args) == 0 {
+		return 1, true
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 || len(args) != 1 {
+		fmt.Fprintln(d.out, "argument must be a single positive number")
+		return 0, false
+	}
+	return n, true
+}
+
+// step simulates the next timestep and prints new errors. It returns false if the simulation is done.
+func (d *debugger) step() bool {
+	if d.s.Done() {
+		return false
+	}
+	if d.maxHistory > 0 {
+		if len(d.history) == d.maxHistory {
+			d.history = d.history[1:]
+		}
+		d.history = append(d.history, d.s.Checkpoint())
+	}
+	errs := len(d.s.Errors())
+	d.s.Step()
+	d.printErrors(errs)
+	return !d.s.Done()
+}
+
+// run steps until the simulation is done, a breakpoint is hit, an error is found or until is reached.
+func (d *debugger) run(until *simulator.Int) {
+	for {
+		if until != nil && d.w.CurrentTime.Cmp(until) != -1 {
+			return
+		}
+		before := make(map[string]string, len(d.breaks))
+		for k := range d.breaks {
+			before[k] = d.breakState(splitBreak(k))
+		}
+		errs := len(d.s.Errors())
+		if !d.step() {
+			return
+		}
+		if len(d.s.Errors()) != errs {
+			return
+		}
+		hit := false
+		for _, k := range sortedBreaks(d.breaks) {
+			if d.breakState(splitBreak(k)) != before[k] {
+				fmt.Fprintf(d.out, "breakpoint: %s changed\n", k)
+				hit = true
+			}
+		}
+		if hit {
+			return
+		}
+	}
+}
+
+// breakState returns the state of an entity which is compared for breakpoints.
+// The progress of trains on lines is ignored, so breakpoints on trains only stop at departures, arrivals
+// and changes of passengers.
+func (d *debugger) breakState(kind, id string) string {
+	if kind == "train" {
+		t := d.w.Trains[id]
+		return fmt.Sprint(t.PositionType, t.Position, t.Passengers.String(), d.passengersIn(id))
+	}
+	return d.describe(kind, id)
+}
+
+func splitBreak(k string) (string, string) {
+	i := strings.Index(k, " ")
+	return k[:i], k[i+1:]
+}
+
+// sortedBreaks returns the breakpoints in the order of debugKinds and natural ID order.
+func sortedBreaks(breaks map[string]bool) []string {
+	var sorted []string
+	for _, kind := range debugKinds {
+		var ids []string
+		for k := range breaks {
+			if kk, id := splitBreak(k); kk == kind {
+				ids = append(ids, id)
+			}
+		}
+		simulator.SortIDs(ids)
+		for _, id := range ids {
+			sorted = append(sorted, kind+" "+id)
+		}
+	}
+	return sorted
+}
+
+func (d *debugger) listBreaks() {
+	if len(d.breaks) == 0 {
+		fmt.Fprintln(d.out, "no breakpoints")
+	}
+	for _, k := range sortedBreaks(d.breaks) {
+		fmt.Fprintln(d.out, k)
+	}
+}
+
+// printErrors prints all errors after the first skip errors.
+func (d *debugger) printErrors(skip int) {
+	errs := d.s.Errors()
+	for i := skip; i < len(errs); i++ {
+		fmt.Fprintln(d.out, "error:", errs[i].Error())
+	}
+}
+
+// status prints the current timestep and the result if the simulation is done.
+func (d *debugger) status() {
+	// The timestep after the last action is simulated as well
+	last := new(simulator.Int).Add(&d.w.MaxTime, simulator.NewInt(1))
+	fmt.Fprintf(d.out, "timestep %s of %s, %d errors\n", d.w.CurrentTime.String(), last.String(), len(d.s.Errors()))
+	if d.s.Done() {
+		r := d.s.Result()
+		if r.Valid {
+			fmt.Fprintln(d.out, "simulation finished, delay", r.Delay.String())
+		} else {
+			fmt.Fprintln(d.out, "simulation finished, plan is not valid")
+		}
+	}
+}
+
+func (d *debugger) exists(kind, id string) bool {
+	ok := false
+	switch kind {
+	case "train":
+		_, ok = d.w.Trains[id]
+	case "passenger":
+		_, ok = d.w.Passengers[id]
+	case "station":
+		_, ok = d.w.Stations[id]
+	case "line":
+		_, ok = d.w.Lines[id]
+	}
+	return ok
+}
+
+// describe returns the state of an entity, which must exist.
+func (d *debugger) describe(kind, id string) string {
+	var b strings.Builder
+	w := d.w
+	switch kind {
+	case "train":
+		t := w.Trains[id]
+		switch t.PositionType {
+		case simulator.TrainPositionStation:
+			fmt.Fprintf(&b, "train %s at station %s", t.ID, t.Position[0])
+			if t.BoardingPossible {
+				b.WriteString(", boarding possible")
+			}
+			b.WriteString("\n")
+		case simulator.TrainPositionLine:
+			distance := new(simulator.Rat).Mul(&t.PositionSince, &t.Speed)
+			fmt.Fprintf(&b, "train %s on line %s to %s since %s timesteps (distance %s of %s)\n", t.ID, t.Position[0], t.Position[1], t.PositionSince.String(), distance.String(), w.Lines[t.Position[0]].Length.String())
+		default:
+			fmt.Fprintf(&b, "train %s not started\n", t.ID)
+		}
+		fmt.Fprintf(&b, "  speed %s, passengers %s of %s%s\n", t.Speed.String(), t.Passengers.String(), t.Capacity.String(), d.passengersIn(t.ID))
+		for i := range t.Plan {
+			if t.Plan[i].Time.Cmp(&w.CurrentTime) == +1 {
+				fmt.Fprintf(&b, "  next action: %s\n", t.Plan[i].String())
+				break
+			}
+		}
+	case "passenger":
+		p := w.Passengers[id]
+		switch p.PositionType {
+		case simulator.PassengerPositionTrain:
+			fmt.Fprintf(&b, "passenger %s in train %s\n", p.ID, p.Position)
+		default:
+			fmt.Fprintf(&b, "passenger %s at station %s\n", p.ID, p.Position)
+		}
+		fmt.Fprintf(&b, "  size %s, %s -> %s, target time %s\n", p.Size.String(), p.Start, p.Target, p.TargetTime.String())
+		if p.TargetReached.Sign() != 0 {
+			fmt.Fprintf(&b, "  target reached at %s, delay %s\n", p.TargetReached.String(), p.Delay().String())
+		}
+		for i := range p.Legs {
+			fmt.Fprintf(&b, "  leg %s: boarded at %s@%s", p.Legs[i].Train, p.Legs[i].Board.String(), p.Legs[i].From)
+			if p.Legs[i].To != "" {
+				fmt.Fprintf(&b, ", detrained at %s@%s", p.Legs[i].Detrain.String(), p.Legs[i].To)
+			}
+			b.WriteString("\n")
+		}
+		for i := range p.Plan {
+			if p.Plan[i].Time.Cmp(&w.CurrentTime) == +1 {
+				fmt.Fprintf(&b, "  next action: %s\n", p.Plan[i].String())
+				break
+			}
+		}
+	case "station":
+		s := w.Stations[id]
+		fmt.Fprintf(&b, "station %s, trains %s of %s\n", s.ID, s.CurrenTrains.String(), s.Capacity.String())
+		var trains []string
+		for k, t := range w.Trains {
+			if t.PositionType == simulator.TrainPositionStation && t.Position[0] == id {
+				trains = append(trains, k)
+			}
+		}
+		simulator.SortIDs(trains)
+		if len(trains) != 0 {
+			fmt.Fprintf(&b, "  trains: %s\n", strings.Join(trains, " "))
+		}
+		var waiting []string
+		for k, p := range w.Passengers {
+			if p.PositionType == simulator.PassengerPositionStation && p.Position == id {
+				waiting = append(waiting, k)
+			}
+		}
+		simulator.SortIDs(waiting)
+		if len(waiting) != 0 {
+			fmt.Fprintf(&b, "  passengers: %s\n", strings.Join(waiting, " "))
+		}
+	case "line":
+		l := w.Lines[id]
+		fmt.Fprintf(&b, "line %s (%s) length %s, trains %s of %s\n", l.ID, strings.Join(l.End, " - "), l.Length.String(), l.CurrentCapacity.String(), l.MaxCapacity.String())
+		var trains []string
+		for k, t := range w.Trains {
+			if t.PositionType == simulator.TrainPositionLine && t.Position[0] == id {
+				trains = append(trains, k)
+			}
+		}
+		simulator.SortIDs(trains)
+		if len(trains) != 0 {
+			fmt.Fprintf(&b, "  trains: %s\n", strings.Join(trains, " "))
+		}
+	}
+	return b.String()
+}
+
+// passengersIn returns the IDs of all passengers in train as a list for describe.
+func (d *debugger) passengersIn(train string) string {
+	var ids []string
+	for k, p := range d.w.Passengers {
+		if p.PositionType == simulator.PassengerPositionTrain && p.Position == train {
+			ids = append(ids, k)
+		}
+	}
+	if len(ids) == 0 {
+		return ""
+	}
+	simulator.SortIDs(ids)
+	return " (" + strings.Join(ids, " ") + ")"
+}
@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/informatiCup/informatiCup2022/Bahn-Simulator/simulator"
+)
+
+func diffCommand(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	inputPath := fs.String("input", "input.txt", "path to input file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: diff [-input input.txt] old.txt new.txt")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	d, err := diffPlans(*inputPath, fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	err = d.write(os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if d.empty() {
+		return 0
+	}
+	return 1
+}
+
+// planDiff contains the differences between two plans and their simulation results.
+type planDiff struct {
+	trains        []simulator.TrainActionChange
+	passengers    []simulator.PassengerChange
+	before, after simulator.Result
+}
+
+// diffPlans reads both plans for input, compares them and simulates them.
+func diffPlans(input, oldPlan, newPlan string) (planDiff, error) {
+	var d planDiff
+	var worlds [2]*simulator.World
+	for i, plan := range []string{oldPlan, newPlan} {
+		world, err := simulator.ParseInput(input)
+		if err != nil {
+			return d, err
+		}
+		err = simulator.ParsePlan(world, plan)
+		if err != nil {
+			return d, err
+		}
+		worlds[i] = world
+	}
+	d.trains, d.passengers = simulator.DiffPlans(worlds[0], worlds[1])
+
+	// Keep going so that the delay of every passenger is known even for invalid plans
+	d.before = worlds[0].Run(simulator.Options{KeepGoing: true})
+	d.after = worlds[1].Run(simulator.Options{KeepGoing: true})
+	return d, nil
+}
+
+// empty returns whether both plans are equal.
+func (d planDiff) empty() bool {
+	return len(d.trains) == 0 && len(d.passengers) == 0
+}
+
+func (d planDiff) write(out io.Writer) error {
+	train := ""
+	for _, c := range d.trains {
+		if c.Train != train {
+			train = c.Train
+			fmt.Fprintf(out, "Train %s:\n", train)
+		}
+		switch {
+		case c.Old == nil:
+			fmt.Fprintf(out, "  + %s\n", c.New.String())
+		case c.New == nil:
+			fmt.Fprintf(out, "  - %s\n", c.Old.String())
+		default:
+			fmt.Fprintf(out, "  ~ %s -> %s\n", c.Old.String(), c.New.Time.String())
+		}
+	}
+
+	for _, c := range d.passengers {
+		fmt.Fprintf(out, "Passenger %s:\n", c.Passenger)
+		for i := range c.Old {
+			fmt.Fprintf(out, "  - %s\n", c.Old[i].String())
+		}
+		for i := range c.New {
+			fmt.Fprintf(out, "  + %s\n", c.New[i].String())
+		}
+	}
+
+	return writeScoreChange(out, d.before, d.after)
+}
+
+// writeScoreChange writes the changed delays of all passengers and the score of both results.
+func writeScoreChange(out io.Writer, before, after simulator.Result) error {
+	// Delays are reported for all passengers, as changes of other passengers can delay them
+	beforeDelay := make(map[string]*simulator.Int, len(before.Passengers))
+	for _, p := range before.Passengers {
+		beforeDelay[p.ID] = p.Delay
+	}
+	header := false
+	for _, p := range after.Passengers {
+		delay, ok := beforeDelay[p.ID]
+		if !ok || delay.Cmp(p.Delay) == 0 {
+			continue
+		}
+		if !header {
+			fmt.Fprintln(out, "Delays:")
+			header = true
+		}
+		change := "-"
+		if delay.Cmp(simulator.InvalidDelay) != 0 && p.Delay.Cmp(simulator.InvalidDelay) != 0 {
+			change = delta(delay, p.Delay)
+		}
+		fmt.Fprintf(out, "  %s: %s -> %s (%s)\n", p.ID, delay.String(), p.Delay.String(), change)
+	}
+
+	_, err := fmt.Fprintf(out, "Score: %s -> %s (%s)\n", score(before), score(after), scoreDelta(before, after))
+	return err
+}
+
+// score returns the delay of a valid result.
+func score(r simulator.Result) string {
+	if !r.Valid {
+		return "invalid"
+	}
+	return r.Delay.String()
+}
+
+func scoreDelta(before, after simulator.Result) string {
+	if !before.Valid || !after.Valid {
+		return "-"
+	}
+	return delta(before.Delay, after.Delay)
+}
+
+// delta returns b-a with sign.
+func delta(a, b *simulator.Int) string {
+	d := new(simulator.Int).Sub(b, a)
+	if d.Sign() == +1 {
+		return "+" + d.String()
+	}
+	return d.String()
+}
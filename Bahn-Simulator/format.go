@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/informatiCup/informatiCup2022/Bahn-Simulator/simulator"
+)
+
+func fmtCommand(args []string) int {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	inputPath := fs.String("input", "input.txt", "path to input file")
+	outputPath := fs.String("output", "output.txt", "path to the plan which is rewritten ('-' reads from stdin and writes to stdout)")
+	check := fs.Bool("check", false, "do not rewrite the plan, exit with 1 if it is not formatted canonically")
+	fs.Parse(args)
+
+	var plan []byte
+	var err error
+	if *outputPath == "-" {
+		plan, err = io.ReadAll(os.Stdin)
+	} else {
+		plan, err = os.ReadFile(*outputPath)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can not read plan:", err)
+		return 2
+	}
+
+	formatted, err := formatPlan(*inputPath, *outputPath, plan)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	switch {
+	case *check:
+		if !isFormatted(plan, formatted) {
+			fmt.Println(*outputPath, "is not formatted")
+			return 1
+		}
+	case *outputPath == "-":
+		_, err = os.Stdout.Write(formatted)
+	case !bytes.Equal(plan, formatted):
+		err = os.WriteFile(*outputPath, formatted, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can not write plan:", err)
+		return 2
+	}
+	return 0
+}
+
+// formatPlan returns plan in canonical form. name is used for parse errors.
+func formatPlan(input, name string, plan []byte) ([]byte, error) {
+	world, err := simulator.ParseInput(input)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	err = simulator.FormatPlan(world, bytes.NewReader(plan), &b)
+	var perr simulator.ParseErrors
+	if errors.As(err, &perr) {
+		if name == "-" {
+			name = "<stdin>"
+		}
+		for i := range perr {
+			perr[i].File = name
+		}
+	}
+	return b.Bytes(), err
+}
+
+// isFormatted returns whether plan equals its canonical form formatted.
+// Whitespace at the end of the file is ignored, the plans of the competition end differently.
+func isFormatted(plan, formatted []byte) bool {
+	return bytes.Equal(bytes.TrimRight(plan, " \t\r\n"), bytes.TrimRight(formatted, " \t\r\n"))
+}
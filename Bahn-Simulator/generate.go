@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/informatiCup/informatiCup2022/Bahn-Simulator/simulator"
+)
+
+func generateCommand(args []string) int {
+	opt := simulator.DefaultGenerateOptions()
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	outputPath := fs.String("output", "-", "path the input is written to ('-' writes to stdout)")
+	fs.Int64Var(&opt.Seed, "seed", opt.Seed, "seed of the random number generator")
+	fs.StringVar(&opt.Topology, "topology", opt.Topology, "topology of the network ("+strings.Join(simulator.Topologies, ", ")+")")
+	fs.IntVar(&opt.Stations, "stations", opt.Stations, "number of stations")
+	fs.IntVar(&opt.Lines, "lines", opt.Lines, "number of lines, additional lines connect random stations (0: only the lines of the topology)")
+	fs.IntVar(&opt.Trains, "trains", opt.Trains, "number of trains")
+	fs.Float64Var(&opt.Wildcards, "wildcards", opt.Wildcards, "share of trains starting at '*' (0 to 1)")
+	fs.IntVar(&opt.Passengers, "passengers", opt.Passengers, "number of passenger groups")
+	fs.Var(&opt.StationCapacity, "station-capacity", "range of station capacities")
+	fs.Var(&opt.LineCapacity, "line-capacity", "range of line capacities")
+	fs.Var(&opt.LineLength, "length", "range of line lengths")
+	fs.Var(&opt.TrainSpeed, "speed", "range of train speeds")
+	fs.Var(&opt.TrainCapacity, "train-capacity", "range of train capacities")
+	fs.Var(&opt.GroupSize, "group-size", "range of passenger group sizes")
+	fs.Var(&opt.TargetTime, "target-time", "range of passenger target times")
+	fs.Parse(args)
+
+	input, err := generate(opt)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can not generate input:", err)
+		return 1
+	}
+	if *outputPath == "-" {
+		_, err = os.Stdout.Write(input)
+	} else {
+		err = os.WriteFile(*outputPath, input, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can not write input:", err)
+		return 2
+	}
+	return 0
+}
+
+// generate creates a random input. It is verified by parsing it again.
+func generate(opt simulator.GenerateOptions) ([]byte, error) {
+	world, err := simulator.Generate(opt)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	err = simulator.WriteInput(world, &b)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := simulator.ParseInputReader(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		return nil, fmt.Errorf("generated input can not be parsed: %w", err)
+	}
+	if errs := parsed.ValidateStart(); len(errs) != 0 {
+		return nil, fmt.Errorf("generated input is not valid: %w", errs[0])
+	}
+	return b.Bytes(), nil
+}
@@ -1,3 +1,3 @@
 module github.com/informatiCup/informatiCup2022/Bahn-Simulator
 
-go 1.16
+go 1.20
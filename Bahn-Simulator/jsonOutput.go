@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/informatiCup/informatiCup2022/Bahn-Simulator/simulator"
+)
+
+type jsonResult struct {
+	Valid      bool            `json:"valid"`
+	Delay      *simulator.Int  `json:"delay"`
+	EndTime    *simulator.Int  `json:"end_time"`
+	Passengers []jsonPassenger `json:"passengers"`
+	Errors     []jsonError     `json:"errors"`
+}
+
+type jsonPassenger struct {
+	ID         string         `json:"id"`
+	Size       *simulator.Int `json:"size"`
+	TargetTime *simulator.Int `json:"target_time"`
+	Arrival    *simulator.Int `json:"arrival"`
+	Delay      *simulator.Int `json:"delay"`
+}
+
+type jsonError struct {
+	Time    *simulator.Int `json:"time,omitempty"`
+	Entity  string         `json:"entity,omitempty"`
+	ID      string         `json:"id,omitempty"`
+	File    string         `json:"file,omitempty"`
+	Line    int            `json:"line,omitempty"`
+	Column  int            `json:"column,omitempty"`
+	Kind    string         `json:"kind,omitempty"`
+	Message string         `json:"message"`
+}
+
+// writeJSON writes the result of a simulation as a single JSON document.
+// If readErr is not nil, the input or plan could not be read and result is ignored.
+func writeJSON(out io.Writer, result simulator.Result, readErr error) error {
+	r := jsonResult{
+		Valid:      result.Valid,
+		Delay:      result.Delay,
+		EndTime:    result.EndTime,
+		Passengers: make([]jsonPassenger, 0, len(result.Passengers)),
+		Errors:     make([]jsonError, 0, len(result.Errors)),
+	}
+
+	if readErr != nil {
+		r.Valid = false
+		r.Delay = simulator.NewInt(-1)
+		var perr simulator.ParseErrors
+		if errors.As(readErr, &perr) {
+			for i := range perr {
+				r.Errors = append(r.Errors, jsonError{
+					File:    perr[i].File,
+					Line:    perr[i].Line,
+					Column:  perr[i].Column,
+					Kind:    perr[i].Kind.String(),
+					Message: perr[i].Error(),
+				})
+			}
+		} else {
+			r.Errors = append(r.Errors, jsonError{Message: readErr.Error()})
+		}
+	}
+
+	for i := range result.Passengers {
+		p := result.Passengers[i]
+		r.Passengers = append(r.Passengers, jsonPassenger{
+			ID:         p.ID,
+			Size:       p.Size,
+			TargetTime: p.TargetTime,
+			Arrival:    p.Arrival,
+			Delay:      p.Delay,
+		})
+	}
+
+	for i := range result.Errors {
+		e := jsonError{Message: result.Errors[i].Error()}
+		var serr *simulator.SimulationError
+		if errors.As(result.Errors[i], &serr) {
+			e.Time = new(simulator.Int).Set(&serr.Time)
+			e.Entity = serr.Entity.String()
+			e.ID = serr.ID
+			e.Message = serr.Err.Error()
+		}
+		r.Errors = append(r.Errors, e)
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(r)
+}
@@ -16,20 +16,49 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
-	"math/big"
 	"os"
 	"runtime/pprof"
-	"sync"
+	"strings"
+
+	"github.com/informatiCup/informatiCup2022/Bahn-Simulator/simulator"
 )
 
+// commands contains all subcommands. Without a subcommand, a plan is validated and scored.
+var commands = map[string]func(args []string) int{
+	"debug":    debugCommand,
+	"diff":     diffCommand,
+	"fmt":      fmtCommand,
+	"generate": generateCommand,
+	"minimize": minimizeCommand,
+	"repair":   repairCommand,
+	"report":   reportCommand,
+	"solve":    solveCommand,
+}
+
 func main() {
-	inputPath := flag.String("input", "input.txt", "path to input file")
-	outputPath := flag.String("output", "output.txt", "path to input file")
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
+	inputPath := flag.String("input", "input.txt", "path to input file ('-' reads input followed by the plan from stdin)")
+	outputPath := flag.String("output", "output.txt", "path to output file ('-' reads from stdin)")
 	profile := flag.String("pprof", "", "if set to a path, a pprof profile will be written")
 	verbose := flag.Bool("verbose", false, "verbose output")
+	format := flag.String("format", "text", "output format ('text' or 'json')")
+	tracePath := flag.String("trace", "", "if set to a path, all events of the simulation will be written")
+	traceFormat := flag.String("trace-format", "jsonl", "format of the trace ('jsonl' or 'csv')")
+	tickByTick := flag.Bool("tick-by-tick", false, "simulate every single timestep instead of skipping idle timesteps (reference mode)")
+	keepGoing := flag.Bool("keep-going", false, "continue after rule violations and report all of them grouped by entity")
+	checkpointPath := flag.String("checkpoint", "", "if set to a path, a checkpoint of the simulation will be written")
+	checkpointAt := flag.Int64("checkpoint-at", 0, "timestep of the checkpoint (the next simulated timestep is used if nothing happens in it)")
+	resumePath := flag.String("resume", "", "if set to a path, the simulation is resumed from the checkpoint")
 	flag.Parse()
 
 	if *profile != "" {
@@ -46,134 +75,177 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	delay, successful := runSimulation(*inputPath, *outputPath, *verbose)
-	if !successful {
-		os.Exit(1)
-	}
-
-	if *verbose {
-		fmt.Println("Printing score")
-	}
-	fmt.Println(delay.String())
-}
-
-func runSimulation(input, output string, verbose bool) (*big.Int, bool) {
-	world, err := ParseInput(input)
-	if err != nil {
-		fmt.Println("Can not read input file:", err)
-		return big.NewInt(-1), false
-	}
-
-	if verbose {
-		fmt.Println("Read output plans")
-	}
+	opt := simulator.Options{KeepGoing: *keepGoing, TickByTick: *tickByTick}
 
-	err = ParsePlan(world, output)
-	if err != nil {
-		fmt.Println("Can not read input file:", err)
-		return big.NewInt(-1), false
+	if *resumePath != "" {
+		c, err := simulator.ReadCheckpointFile(*resumePath)
+		if err != nil {
+			fmt.Println("Can not read checkpoint:", err)
+			os.Exit(2)
+		}
+		opt.Resume = c
 	}
 
-	if verbose {
-		fmt.Println("Validating word begin")
-	}
-	errs := world.ValidateStart()
-	if errs != nil {
-		fmt.Println("initial validation failed:")
-		for i := range errs {
-			fmt.Println(errs[i].Error())
+	if *checkpointPath != "" {
+		opt.CheckpointAt.SetInt64(*checkpointAt)
+		opt.Checkpoint = func(c *simulator.Checkpoint) {
+			err := writeCheckpoint(*checkpointPath, c)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "can not write checkpoint:", err)
+			}
 		}
-		return big.NewInt(-1), false
 	}
 
-	// Run simulation
-	for world.CurrentTime.Cmp(&world.MaxTime) != +1 {
-		world.CurrentTime.Add(&world.CurrentTime, big.NewInt(1))
-		if verbose {
-			fmt.Println("Timestep", world.CurrentTime.String())
+	if *tracePath != "" {
+		f, err := os.Create(*tracePath)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
 		}
-		var errFound bool
-
-		e := make(chan error, 1)
-		var wg sync.WaitGroup
-
-		// Trains
-		for k := range world.Trains {
-			wg.Add(1)
-			go world.Trains[k].Update(world, e, &wg)
+		tw, err := newTraceWriter(f, *traceFormat)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(2)
 		}
-
-		go func() {
-			wg.Wait()
-			close(e)
+		opt.Trace = tw.Write
+		defer func() {
+			err := tw.Close()
+			if err == nil {
+				err = f.Close()
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "can not write trace:", err)
+			}
 		}()
+	}
 
-		for err := range e {
-			errFound = true
-			fmt.Println("trains -", world.CurrentTime.String(), "-", err.Error())
+	switch *format {
+	case "text":
+		if *verbose {
+			opt.Verbose = os.Stdout
 		}
-
-		if errFound {
-			return big.NewInt(-1), false
+		delay, successful := runSimulation(*inputPath, *outputPath, opt)
+		if !successful {
+			os.Exit(1)
 		}
 
-		// Passengers
-		e = make(chan error, 1)
-
-		for k := range world.Passengers {
-			wg.Add(1)
-			go world.Passengers[k].Update(world, e, &wg)
+		if *verbose {
+			fmt.Println("Printing score")
 		}
+		fmt.Println(delay.String())
+	case "json":
+		// Verbose output goes to stderr to keep stdout a valid JSON document
+		if *verbose {
+			opt.Verbose = os.Stderr
+		}
+		result, err := simulate(*inputPath, *outputPath, opt)
+		if e := writeJSON(os.Stdout, result, err); e != nil {
+			fmt.Fprintln(os.Stderr, e)
+			os.Exit(2)
+		}
+		if err != nil || !result.Valid {
+			os.Exit(1)
+		}
+	default:
+		fmt.Printf("unknown format '%s'\n", *format)
+		os.Exit(2)
+	}
+}
 
-		go func() {
-			wg.Wait()
-			close(e)
-		}()
-
-		for err := range e {
-			errFound = true
-			fmt.Println("passengers -", world.CurrentTime.String(), "-", err.Error())
+func runSimulation(input, output string, opt simulator.Options) (*simulator.Int, bool) {
+	result, err := simulate(input, output, opt)
+	if err != nil {
+		var perr simulator.ParseErrors
+		if errors.As(err, &perr) {
+			// Print parse errors without prefix so that editors can jump to 'file:line:'
+			for i := range perr {
+				fmt.Println(perr[i].Error())
+			}
+		} else {
+			fmt.Println("Can not read input file:", err)
 		}
+		return simulator.NewInt(-1), false
+	}
 
-		if errFound {
-			return big.NewInt(-1), false
+	if opt.KeepGoing {
+		printGroupedErrors(result.Errors)
+	} else {
+		for i := range result.Errors {
+			fmt.Println(result.Errors[i].Error())
 		}
+	}
+	return result.Delay, result.Valid
+}
 
-		// Validate
-		if verbose {
-			fmt.Println("Validate", world.CurrentTime.String())
+// simulate reads input and plan and runs the simulation.
+// An error is returned if input or plan can not be read.
+func simulate(input, output string, opt simulator.Options) (simulator.Result, error) {
+	world, err := readWorld(input, output, opt.Verbose)
+	if err != nil {
+		var perr simulator.ParseErrors
+		if errors.As(err, &perr) {
+			for i := range perr {
+				if perr[i].File == "" {
+					perr[i].File = "<stdin>"
+				}
+			}
 		}
+		return simulator.Result{Valid: false, Delay: simulator.NewInt(-1)}, err
+	}
+
+	return world.Run(opt), nil
+}
 
-		errs = world.Validate()
-		if errs != nil {
-			fmt.Println("validation", world.CurrentTime.String(), "failed:")
-			for i := range errs {
-				fmt.Println(errs[i].Error())
+// printGroupedErrors prints errors grouped by trains, passengers, lines, stations and the world.
+func printGroupedErrors(errs []error) {
+	grouped := simulator.GroupErrors(errs)
+	for _, entity := range []simulator.Entity{simulator.EntityTrain, simulator.EntityPassenger, simulator.EntityLine, simulator.EntityStation, simulator.EntityWorld} {
+		ids := make([]string, 0, len(grouped[entity]))
+		for k := range grouped[entity] {
+			ids = append(ids, k)
+		}
+		simulator.SortIDs(ids)
+		for _, id := range ids {
+			fmt.Println(strings.TrimSpace(entity.String()+" "+id) + ":")
+			for _, err := range grouped[entity][id] {
+				fmt.Println("\t" + err.Error())
 			}
-			return big.NewInt(-1), false
 		}
 	}
+}
 
-	// Check result and calculate delay
-	if verbose {
-		fmt.Println("Calculating score")
+// writeCheckpoint writes c to the file at path.
+func writeCheckpoint(path string, c *simulator.Checkpoint) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
 	}
+	_, err = c.WriteTo(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-	delay := big.NewInt(0)
-	valid := true
+// readWorld reads input and plan. A path of "-" denotes stdin.
+// If input is read from stdin, the plan is expected to follow the input in the same document.
+func readWorld(input, output string, verbose io.Writer) (*simulator.World, error) {
+	if input == "-" {
+		return simulator.ParseCombinedReader(os.Stdin)
+	}
 
-	for k := range world.Passengers {
-		d := world.Passengers[k].Delay()
-		if d.Cmp(InvalidDelay) == 0 {
-			valid = false
-			fmt.Println("passenger", k, "does not reach goal")
-		}
-		delay.Add(delay, d)
+	world, err := simulator.ParseInput(input)
+	if err != nil {
+		return world, err
 	}
 
-	if !valid {
-		return big.NewInt(-1), false
+	if verbose != nil {
+		fmt.Fprintln(verbose, "Read output plans")
 	}
 
-	return delay, true
+	if output == "-" {
+		return world, simulator.ParsePlanReader(world, os.Stdin)
+	}
+	return world, simulator.ParsePlan(world, output)
 }
@@ -16,12 +16,24 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"path"
+	"strings"
 	"testing"
+
+	"github.com/informatiCup/informatiCup2022/Bahn-Simulator/simulator"
 )
 
+var update = flag.Bool("update", false, "update the expected results of the test cases in test/")
+
+// TestMain simulates all test cases in test/ and compares the results with the expected results, if present:
+// score.txt contains the delay of a valid plan, errors.txt contains all errors of a rejected plan, one per line.
+// Test cases without expected results must be valid. Run with -update to write the expected results.
 func TestMain(t *testing.T) {
 	dirs, err := os.ReadDir("test/")
 	if err != nil {
@@ -29,14 +41,246 @@ func TestMain(t *testing.T) {
 		t.FailNow()
 	}
 	for i := range dirs {
-		if dirs[i].IsDir() {
-			input := path.Join("test", dirs[i].Name(), "input.txt")
-			output := path.Join("test", dirs[i].Name(), "output.txt")
-			_, successful := runSimulation(input, output, false)
-			if !successful {
-				fmt.Println("Test", dirs[i].Name(), "failed")
-				t.Fail()
+		if !dirs[i].IsDir() {
+			continue
+		}
+		dir := path.Join("test", dirs[i].Name())
+		result, err := simulate(path.Join(dir, "input.txt"), path.Join(dir, "output.txt"), simulator.Options{})
+		score, errs := goldenResult(result, err)
+
+		if *update {
+			updateGolden(t, dir, score, errs)
+			continue
+		}
+
+		expectedScore, scoreErr := os.ReadFile(path.Join(dir, "score.txt"))
+		expectedErrs, errsErr := os.ReadFile(path.Join(dir, "errors.txt"))
+		switch {
+		case scoreErr == nil:
+			if score != string(expectedScore) || errs != "" {
+				t.Errorf("%s: expected score %s, got %s%s", dirs[i].Name(), strings.TrimSpace(string(expectedScore)), score, errs)
+			}
+		case errsErr == nil:
+			if errs != string(expectedErrs) {
+				t.Errorf("%s: expected errors\n%s\ngot\n%s", dirs[i].Name(), expectedErrs, errs)
+			}
+		default:
+			if errs != "" {
+				t.Errorf("%s: plan not valid:\n%s", dirs[i].Name(), errs)
+			}
+		}
+	}
+}
+
+// goldenResult returns the contents of score.txt and errors.txt for the result of simulate.
+// score is empty if the plan is not valid.
+func goldenResult(result simulator.Result, err error) (string, string) {
+	var errs strings.Builder
+	if err != nil {
+		var perr simulator.ParseErrors
+		if errors.As(err, &perr) {
+			for i := range perr {
+				errs.WriteString(perr[i].Error() + "\n")
+			}
+		} else {
+			errs.WriteString(err.Error() + "\n")
+		}
+		return "", errs.String()
+	}
+	for i := range result.Errors {
+		errs.WriteString(result.Errors[i].Error() + "\n")
+	}
+	if !result.Valid {
+		return "", errs.String()
+	}
+	return result.Delay.String() + "\n", errs.String()
+}
+
+// updateGolden writes the expected results of the test case in dir and removes outdated ones.
+func updateGolden(t *testing.T, dir, score, errs string) {
+	t.Helper()
+	write, remove := path.Join(dir, "score.txt"), path.Join(dir, "errors.txt")
+	content := score
+	if score == "" {
+		write, remove = remove, write
+		content = errs
+	}
+	err := os.WriteFile(write, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Remove(remove)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	input := path.Join("test", "simple", "input.txt")
+	output := path.Join("test", "simple", "output.txt")
+	result, err := simulate(input, output, simulator.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	err = writeJSON(&b, result, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		Valid      bool
+		Delay      int
+		EndTime    int `json:"end_time"`
+		Passengers []struct {
+			ID      string
+			Arrival int
+			Delay   int
+		}
+	}
+	err = json.Unmarshal(b.Bytes(), &decoded)
+	if err != nil {
+		t.Fatal("invalid json:", err)
+	}
+	if !decoded.Valid || decoded.Delay != 9 || decoded.EndTime != 8 || len(decoded.Passengers) != 2 {
+		t.Errorf("wrong result: %s", b.String())
+	}
+	if decoded.Passengers[0].ID != "P1" || decoded.Passengers[0].Arrival != 6 || decoded.Passengers[0].Delay != 9 {
+		t.Errorf("wrong passenger: %+v", decoded.Passengers[0])
+	}
+}
+
+func TestReportCSV(t *testing.T) {
+	input := path.Join("test", "simple", "input.txt")
+	output := path.Join("test", "simple", "output.txt")
+	result, err := simulate(input, output, simulator.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sortByContribution(result.Passengers)
+	var b bytes.Buffer
+	err = writeReportCSV(&b, result.Passengers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "passenger,start,target,size,target_time,arrival,delay,trains,board,detrain,wait\n" +
+		"P1,S2,S3,3,3,6,9,T2,1@S2,6@S3,1@S2\n" +
+		"P2,S2,S1,10,3,3,0,T1,1@S2,3@S1,1@S2\n"
+	if b.String() != expected {
+		t.Errorf("wrong report:\n%s", b.String())
+	}
+}
+
+// BenchmarkRunSimulation runs all test instances including reading the files.
+func BenchmarkRunSimulation(b *testing.B) {
+	dirs, err := os.ReadDir("test/")
+	if err != nil {
+		b.Fatal("can not read test dir:", err)
+	}
+
+	// runSimulation prints errors and results to stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer devNull.Close()
+	stdout := os.Stdout
+	os.Stdout = devNull
+	defer func() { os.Stdout = stdout }()
+
+	for i := range dirs {
+		if !dirs[i].IsDir() {
+			continue
+		}
+		// Rejected plans are not benchmarked
+		if _, err := os.Stat(path.Join("test", dirs[i].Name(), "errors.txt")); err == nil {
+			continue
+		}
+		input := path.Join("test", dirs[i].Name(), "input.txt")
+		output := path.Join("test", dirs[i].Name(), "output.txt")
+		b.Run(dirs[i].Name(), func(b *testing.B) {
+			b.ReportAllocs()
+			for j := 0; j < b.N; j++ {
+				_, successful := runSimulation(input, output, simulator.Options{})
+				if !successful {
+					b.Fatal("test", dirs[i].Name(), "failed")
+				}
 			}
+		})
+	}
+}
+
+func TestSolvePlan(t *testing.T) {
+	input, err := os.ReadFile(path.Join("test", "simple", "input.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, result, err := solve(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid || len(plan) == 0 {
+		t.Errorf("invalid plan: %v\n%s", result.Errors, plan)
+	}
+}
+
+func TestPlansFormatted(t *testing.T) {
+	dirs, err := os.ReadDir("test/")
+	if err != nil {
+		t.Fatal("can not read test dir:", err)
+	}
+	for i := range dirs {
+		if !dirs[i].IsDir() {
+			continue
+		}
+		output := path.Join("test", dirs[i].Name(), "output.txt")
+		code := fmtCommand([]string{"-check", "-input", path.Join("test", dirs[i].Name(), "input.txt"), "-output", output})
+		if code != 0 {
+			t.Errorf("%s is not formatted, run 'fmt' (exit code %d)", output, code)
 		}
 	}
 }
+
+func TestDebugger(t *testing.T) {
+	world, err := readWorld(path.Join("test", "simple", "input.txt"), path.Join("test", "simple", "output.txt"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	d := newDebugger(world, simulator.Options{TickByTick: true}, 10, &out)
+	d.repl(strings.NewReader("run until 3\nshow P1\nback 2\nshow train T1\nbreak passenger P1\nrun\nrun\nquit\n"))
+
+	for _, want := range []string{
+		"timestep 3 of 8",
+		"passenger P1 in train T2\n  size 3, S2 -> S3, target time 3\n  leg T2: boarded at 1@S2\n  next action: 6 Detrain\n",
+		"(1) train T1 at station S2, boarding possible\n  speed 5.5, passengers 10 of 30 (P2)\n",
+		"breakpoint: passenger P1 changed\ntimestep 6 of 8",
+		"simulation finished, delay 9",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("missing %q in output:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWriteScoreChange(t *testing.T) {
+	before := simulator.Result{Delay: simulator.NewInt(-1), Passengers: []simulator.PassengerResult{
+		{ID: "P1", Delay: simulator.NewInt(-1)},
+		{ID: "P2", Delay: simulator.NewInt(4)},
+	}}
+	after := simulator.Result{Valid: true, Delay: simulator.NewInt(3), Passengers: []simulator.PassengerResult{
+		{ID: "P1", Delay: simulator.NewInt(2)},
+		{ID: "P2", Delay: simulator.NewInt(1)},
+	}}
+	var b bytes.Buffer
+	err := writeScoreChange(&b, before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Delays:\n  P1: -1 -> 2 (-)\n  P2: 4 -> 1 (-3)\nScore: invalid -> 3 (-)\n"
+	if b.String() != want {
+		t.Errorf("wrong output:\n%s", b.String())
+	}
+}
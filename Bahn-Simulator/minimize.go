@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/informatiCup/informatiCup2022/Bahn-Simulator/simulator"
+)
+
+func minimizeCommand(args []string) int {
+	fs := flag.NewFlagSet("minimize", flag.ExitOnError)
+	inputPath := fs.String("input", "input.txt", "path to input file")
+	outputPath := fs.String("output", "output.txt", "path to the failing plan")
+	minInputPath := fs.String("min-input", "min_input.txt", "path the minimized input is written to")
+	minOutputPath := fs.String("min-output", "min_output.txt", "path the minimized plan is written to")
+	verbose := fs.Bool("verbose", false, "print progress")
+	fs.Parse(args)
+
+	input, err := os.ReadFile(*inputPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can not read input file:", err)
+		return 2
+	}
+	plan, err := os.ReadFile(*outputPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can not read plan:", err)
+		return 2
+	}
+
+	var progress io.Writer
+	if *verbose {
+		progress = os.Stderr
+	}
+	minInput, minPlan, class, err := simulator.Minimize(input, plan, progress)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can not minimize:", err)
+		return 1
+	}
+
+	err = os.WriteFile(*minInputPath, minInput, 0644)
+	if err == nil {
+		err = os.WriteFile(*minOutputPath, minPlan, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can not write reproducer:", err)
+		return 2
+	}
+	fmt.Println(class)
+	return 0
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/informatiCup/informatiCup2022/Bahn-Simulator/simulator"
+)
+
+func repairCommand(args []string) int {
+	fs := flag.NewFlagSet("repair", flag.ExitOnError)
+	inputPath := fs.String("input", "input.txt", "path to input file")
+	outputPath := fs.String("output", "output.txt", "path to the plan")
+	repairedPath := fs.String("repaired", "repaired_output.txt", "path the repaired plan is written to ('-' writes to stdout)")
+	fs.Parse(args)
+
+	input, err := os.ReadFile(*inputPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can not read input file:", err)
+		return 2
+	}
+	plan, err := os.ReadFile(*outputPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can not read plan:", err)
+		return 2
+	}
+
+	// Keep stdout free for the plan if it is written there
+	log := os.Stdout
+	if *repairedPath == "-" {
+		log = os.Stderr
+	}
+
+	repaired, changes, err := simulator.Repair(input, plan)
+	for i := range changes {
+		fmt.Fprintln(log, changes[i].Violation.Error())
+		fmt.Fprintln(log, "  "+changes[i].Fix)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *repairedPath == "-" {
+		_, err = os.Stdout.Write(repaired)
+	} else {
+		err = os.WriteFile(*repairedPath, repaired, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can not write plan:", err)
+		return 2
+	}
+
+	// Keep going so that the delay of every passenger is known for the invalid plan
+	var results [2]simulator.Result
+	for i, p := range [][]byte{plan, repaired} {
+		world, err := simulator.ParseInputReader(bytes.NewReader(input))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		err = simulator.ParsePlanReader(world, bytes.NewReader(p))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		results[i] = world.Run(simulator.Options{KeepGoing: true})
+	}
+	err = writeScoreChange(log, results[0], results[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/informatiCup/informatiCup2022/Bahn-Simulator/simulator"
+)
+
+var reportHeader = []string{"passenger", "start", "target", "size", "target_time", "arrival", "delay", "trains", "board", "detrain", "wait"}
+
+func reportCommand(args []string) int {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	inputPath := fs.String("input", "input.txt", "path to input file ('-' reads input followed by the plan from stdin)")
+	outputPath := fs.String("output", "output.txt", "path to output file ('-' reads from stdin)")
+	sortBy := fs.String("sort", "contribution", "sort passengers by 'contribution' (largest delay first) or 'id'")
+	format := fs.String("format", "text", "report format ('text' or 'csv')")
+	fs.Parse(args)
+
+	// Keep going so that the journeys of all passengers are reported even if the plan is invalid
+	result, err := simulate(*inputPath, *outputPath, simulator.Options{KeepGoing: true})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch *sortBy {
+	case "contribution":
+		sortByContribution(result.Passengers)
+	case "id":
+	default:
+		fmt.Fprintf(os.Stderr, "unknown sort order '%s'\n", *sortBy)
+		return 2
+	}
+
+	switch *format {
+	case "text":
+		err = writeReportText(os.Stdout, result.Passengers)
+	case "csv":
+		err = writeReportCSV(os.Stdout, result.Passengers)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format '%s'\n", *format)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if !result.Valid {
+		fmt.Fprintln(os.Stderr, "plan is not valid:")
+		for i := range result.Errors {
+			fmt.Fprintln(os.Stderr, result.Errors[i].Error())
+		}
+		return 1
+	}
+	return 0
+}
+
+// sortByContribution sorts passengers by their contribution to the total delay, largest first.
+// Passengers not reaching their target are sorted to the front.
+func sortByContribution(passengers []simulator.PassengerResult) {
+	sort.SliceStable(passengers, func(i, j int) bool {
+		a, b := passengers[i], passengers[j]
+		if (a.Arrival == nil) != (b.Arrival == nil) {
+			return a.Arrival == nil
+		}
+		return a.Delay.Cmp(b.Delay) == +1
+	})
+}
+
+func reportRow(p simulator.PassengerResult) []string {
+	arrival := "-"
+	if p.Arrival != nil {
+		arrival = p.Arrival.String()
+	}
+	trains := make([]string, len(p.Legs))
+	board := make([]string, len(p.Legs))
+	detrain := make([]string, len(p.Legs))
+	waits := p.Waits()
+	wait := make([]string, len(p.Legs))
+	for i := range p.Legs {
+		trains[i] = p.Legs[i].Train
+		board[i] = p.Legs[i].Board.String() + "@" + p.Legs[i].From
+		detrain[i] = "-"
+		if p.Legs[i].To != "" {
+			detrain[i] = p.Legs[i].Detrain.String() + "@" + p.Legs[i].To
+		}
+		wait[i] = waits[i].String() + "@" + p.Legs[i].From
+	}
+	return []string{p.ID, p.Start, p.Target, p.Size.String(), p.TargetTime.String(), arrival, p.Delay.String(),
+		strings.Join(trains, ";"), strings.Join(board, ";"), strings.Join(detrain, ";"), strings.Join(wait, ";")}
+}
+
+func writeReportText(out io.Writer, passengers []simulator.PassengerResult) error {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(reportHeader, "\t")))
+	for i := range passengers {
+		fmt.Fprintln(tw, strings.Join(reportRow(passengers[i]), "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeReportCSV(out io.Writer, passengers []simulator.PassengerResult) error {
+	w := csv.NewWriter(out)
+	err := w.Write(reportHeader)
+	if err != nil {
+		return err
+	}
+	for i := range passengers {
+		err = w.Write(reportRow(passengers[i]))
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
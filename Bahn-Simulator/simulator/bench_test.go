@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// benchSizes are the sizes of the synthetic worlds used in benchmarks.
+var benchSizes = []struct {
+	stations, passengers int
+}{
+	{10, 10},
+	{100, 1000},
+	{1000, 10000},
+	{10000, 100000},
+}
+
+// syntheticWorld returns input and plan of a valid world with the given number of stations and passengers.
+//
+// The stations form a chain S1 - S2 - ... - Sn. Train Ti starts at Si and travels the line Li to Si+1.
+// Every passenger travels a single line, half of them arrive one timestep late.
+func syntheticWorld(stations, passengers int) (string, string) {
+	if stations < 2 {
+		stations = 2
+	}
+	trains := stations - 1
+	perTrain := (passengers + trains - 1) / trains
+	if perTrain == 0 {
+		perTrain = 1
+	}
+
+	var input, plan strings.Builder
+	input.WriteString("[Stations]\n")
+	for i := 1; i <= stations; i++ {
+		fmt.Fprintf(&input, "S%d 2\n", i)
+	}
+	input.WriteString("\n[Lines]\n")
+	for i := 1; i <= trains; i++ {
+		fmt.Fprintf(&input, "L%d S%d S%d 1.5 1\n", i, i, i+1)
+	}
+	input.WriteString("\n[Trains]\n")
+	for i := 1; i <= trains; i++ {
+		fmt.Fprintf(&input, "T%d S%d 0.75 %d\n", i, i, perTrain)
+		fmt.Fprintf(&plan, "[Train:T%d]\n2 Depart L%d\n\n", i, i)
+	}
+	input.WriteString("\n[Passengers]\n")
+	for i := 0; i < passengers; i++ {
+		train := i%trains + 1
+		fmt.Fprintf(&input, "P%d S%d S%d 1 %d\n", i+1, train, train+1, 3+i%2)
+		fmt.Fprintf(&plan, "[Passenger:P%d]\n1 Board T%d\n4 Detrain\n\n", i+1, train)
+	}
+	return input.String(), plan.String()
+}
+
+// benchWorld parses a synthetic world including its plan.
+func benchWorld(b *testing.B, input, plan string) *World {
+	w, err := ParseInputReader(strings.NewReader(input))
+	if err != nil {
+		b.Fatal("can not parse input:", err)
+	}
+	err = ParsePlanReader(w, strings.NewReader(plan))
+	if err != nil {
+		b.Fatal("can not parse plan:", err)
+	}
+	return w
+}
+
+// runBenchSizes runs f as sub-benchmark for all benchSizes.
+func runBenchSizes(b *testing.B, f func(b *testing.B, input, plan string)) {
+	for _, size := range benchSizes {
+		input, plan := syntheticWorld(size.stations, size.passengers)
+		b.Run(fmt.Sprintf("stations=%d/passengers=%d", size.stations, size.passengers), func(b *testing.B) {
+			f(b, input, plan)
+		})
+	}
+}
+
+func TestSyntheticWorld(t *testing.T) {
+	input, plan := syntheticWorld(10, 25)
+	w, err := ParseInputReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ParsePlanReader(w, strings.NewReader(plan))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := w.Run(Options{})
+	// Passengers with an even index are one timestep late
+	if !r.Valid || r.Delay.String() != "13" {
+		t.Errorf("wrong result: %v %s %v", r.Valid, r.Delay, r.Errors)
+	}
+}
+
+func BenchmarkParseInput(b *testing.B) {
+	runBenchSizes(b, func(b *testing.B, input, plan string) {
+		b.SetBytes(int64(len(input)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := ParseInputReader(strings.NewReader(input))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkParsePlan(b *testing.B) {
+	runBenchSizes(b, func(b *testing.B, input, plan string) {
+		b.SetBytes(int64(len(plan)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			w, err := ParseInputReader(strings.NewReader(input))
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.StartTimer()
+			err = ParsePlanReader(w, strings.NewReader(plan))
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkValidate(b *testing.B) {
+	runBenchSizes(b, func(b *testing.B, input, plan string) {
+		w := benchWorld(b, input, plan)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if errs := w.Validate(); len(errs) != 0 {
+				b.Fatal(errs)
+			}
+		}
+	})
+}
+
+func BenchmarkCheckConnected(b *testing.B) {
+	runBenchSizes(b, func(b *testing.B, input, plan string) {
+		w := benchWorld(b, input, plan)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			// Include building the network, which is done once at load time
+			w.UpdateNetwork()
+			if !w.CheckConnected() {
+				b.Fatal("world not connected")
+			}
+		}
+	})
+}
+
+func BenchmarkRun(b *testing.B) {
+	runBenchSizes(b, func(b *testing.B, input, plan string) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			w := benchWorld(b, input, plan)
+			b.StartTimer()
+			if r := w.Run(Options{}); !r.Valid {
+				b.Fatal(r.Errors)
+			}
+		}
+	})
+}
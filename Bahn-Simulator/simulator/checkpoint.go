@@ -0,0 +1,282 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Checkpoint is the state of a simulation after a timestep: the snapshot of the world together with the errors
+// found so far. It does not share any memory with the simulated world.
+type Checkpoint struct {
+	Snapshot *Snapshot
+
+	errs []error
+	// violating is the state of KeepGoing mode, see Simulation
+	violating map[string]bool
+	done      bool
+}
+
+// Time returns the last simulated timestep of c.
+func (c *Checkpoint) Time() *Int {
+	return new(Int).Set(&c.Snapshot.Time)
+}
+
+// Checkpoint returns the current state of s.
+func (s *Simulation) Checkpoint() *Checkpoint {
+	c := &Checkpoint{
+		Snapshot:  s.w.Snapshot(),
+		errs:      append([]error(nil), s.errs...),
+		violating: make(map[string]bool, len(s.violating)),
+		done:      s.done,
+	}
+	for k, v := range s.violating {
+		c.violating[k] = v
+	}
+	return c
+}
+
+// Restore sets s to the state of c. The world of s must be created from the same input as the world of c,
+// but the plans can differ after the time of c.
+func (s *Simulation) Restore(c *Checkpoint) error {
+	err := s.w.Restore(c.Snapshot)
+	if err != nil {
+		return err
+	}
+	s.errs = append([]error(nil), c.errs...)
+	s.violating = make(map[string]bool, len(c.violating))
+	for k, v := range c.violating {
+		s.violating[k] = v
+	}
+	s.done = false
+	s.result = Result{}
+	if s.s != nil {
+		// The schedule finds its position again
+		s.s.next = 0
+	}
+	if c.done {
+		if len(s.errs) != 0 {
+			s.finish(invalidResult(s.errs))
+		} else {
+			s.score()
+		}
+	}
+	return nil
+}
+
+// ResumeSimulation continues a simulation of w from c. See Simulation.Restore for the requirements on c.
+func (w *World) ResumeSimulation(opt Options, c *Checkpoint) (*Simulation, error) {
+	s := &Simulation{w: w, opt: opt}
+	if opt.Trace != nil {
+		w.recorder = new(eventRecorder)
+	}
+	w.Network()
+	s.s = newSchedule(w, opt.TickByTick)
+	return s, s.Restore(c)
+}
+
+// checkpointVersion is the version of the file format of checkpoints.
+const checkpointVersion = 1
+
+type jsonCheckpoint struct {
+	Version    int                              `json:"version"`
+	Time       *Int                             `json:"time"`
+	Done       bool                             `json:"done"`
+	Trains     map[string]jsonTrainSnapshot     `json:"trains"`
+	Passengers map[string]jsonPassengerSnapshot `json:"passengers"`
+	Lines      map[string]*Int                  `json:"lines"`
+	Stations   map[string]*Int                  `json:"stations"`
+	Errors     []jsonCheckpointError            `json:"errors"`
+	Violating  []string                         `json:"violating,omitempty"`
+}
+
+type jsonTrainSnapshot struct {
+	Passengers       *Int     `json:"passengers"`
+	Position         []string `json:"position"`
+	PositionSince    *Rat     `json:"position_since"`
+	PositionType     int      `json:"position_type"`
+	BoardingPossible bool     `json:"boarding_possible"`
+}
+
+type jsonPassengerSnapshot struct {
+	TargetReached *Int      `json:"target_reached"`
+	PositionType  int       `json:"position_type"`
+	Position      string    `json:"position"`
+	Legs          []jsonLeg `json:"legs,omitempty"`
+}
+
+type jsonLeg struct {
+	Train   string `json:"train"`
+	From    string `json:"from"`
+	Board   *Int   `json:"board"`
+	To      string `json:"to,omitempty"`
+	Detrain *Int   `json:"detrain,omitempty"`
+}
+
+type jsonCheckpointError struct {
+	Time    *Int   `json:"time"`
+	Entity  Entity `json:"entity"`
+	ID      string `json:"id,omitempty"`
+	Message string `json:"message"`
+}
+
+// WriteTo writes c as JSON document to out.
+func (c *Checkpoint) WriteTo(out io.Writer) (int64, error) {
+	s := c.Snapshot
+	j := jsonCheckpoint{
+		Version:    checkpointVersion,
+		Time:       &s.Time,
+		Done:       c.done,
+		Trains:     make(map[string]jsonTrainSnapshot, len(s.Trains)),
+		Passengers: make(map[string]jsonPassengerSnapshot, len(s.Passengers)),
+		Lines:      s.Lines,
+		Stations:   s.Stations,
+		Errors:     make([]jsonCheckpointError, 0, len(c.errs)),
+	}
+	for k, t := range s.Trains {
+		j.Trains[k] = jsonTrainSnapshot{
+			Passengers:       &t.Passengers,
+			Position:         t.Position,
+			PositionSince:    &t.PositionSince,
+			PositionType:     int(t.PositionType),
+			BoardingPossible: t.BoardingPossible,
+		}
+	}
+	for k, p := range s.Passengers {
+		jp := jsonPassengerSnapshot{
+			TargetReached: &p.TargetReached,
+			PositionType:  int(p.PositionType),
+			Position:      p.Position,
+		}
+		for i := range p.Legs {
+			jp.Legs = append(jp.Legs, jsonLeg{Train: p.Legs[i].Train, From: p.Legs[i].From, Board: &p.Legs[i].Board, To: p.Legs[i].To, Detrain: &p.Legs[i].Detrain})
+		}
+		j.Passengers[k] = jp
+	}
+	for i := range c.errs {
+		var serr *SimulationError
+		if !errors.As(c.errs[i], &serr) {
+			return 0, fmt.Errorf("can not write error '%s'", c.errs[i].Error())
+		}
+		j.Errors = append(j.Errors, jsonCheckpointError{Time: &serr.Time, Entity: serr.Entity, ID: serr.ID, Message: serr.Err.Error()})
+	}
+	for k := range c.violating {
+		j.Violating = append(j.Violating, k)
+	}
+	sort.Strings(j.Violating)
+
+	b, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := out.Write(append(b, '\n'))
+	return int64(n), err
+}
+
+// ReadCheckpoint reads a checkpoint written by Checkpoint.WriteTo from r.
+func ReadCheckpoint(r io.Reader) (*Checkpoint, error) {
+	var j jsonCheckpoint
+	err := json.NewDecoder(r).Decode(&j)
+	if err != nil {
+		return nil, err
+	}
+	if j.Version != checkpointVersion {
+		return nil, fmt.Errorf("unsupported checkpoint version %d", j.Version)
+	}
+	if j.Time == nil {
+		return nil, fmt.Errorf("checkpoint without time")
+	}
+
+	s := &Snapshot{
+		Time:       *j.Time,
+		Trains:     make(map[string]*TrainSnapshot, len(j.Trains)),
+		Passengers: make(map[string]*PassengerSnapshot, len(j.Passengers)),
+		Lines:      make(map[string]*Int, len(j.Lines)),
+		Stations:   make(map[string]*Int, len(j.Stations)),
+	}
+	for k, t := range j.Trains {
+		if t.Passengers == nil || t.PositionSince == nil {
+			return nil, fmt.Errorf("incomplete train '%s'", k)
+		}
+		s.Trains[k] = &TrainSnapshot{
+			Passengers:       *t.Passengers,
+			Position:         t.Position,
+			PositionSince:    *t.PositionSince,
+			PositionType:     TrainPosition(t.PositionType),
+			BoardingPossible: t.BoardingPossible,
+		}
+	}
+	for k, p := range j.Passengers {
+		if p.TargetReached == nil {
+			return nil, fmt.Errorf("incomplete passenger '%s'", k)
+		}
+		ps := &PassengerSnapshot{
+			TargetReached: *p.TargetReached,
+			PositionType:  PassengerPosition(p.PositionType),
+			Position:      p.Position,
+		}
+		for _, l := range p.Legs {
+			if l.Board == nil {
+				return nil, fmt.Errorf("incomplete leg of passenger '%s'", k)
+			}
+			leg := Leg{Train: l.Train, From: l.From, Board: *l.Board, To: l.To}
+			if l.Detrain != nil {
+				leg.Detrain = *l.Detrain
+			}
+			ps.Legs = append(ps.Legs, leg)
+		}
+		s.Passengers[k] = ps
+	}
+	for k, v := range j.Lines {
+		if v == nil {
+			return nil, fmt.Errorf("incomplete line '%s'", k)
+		}
+		s.Lines[k] = v
+	}
+	for k, v := range j.Stations {
+		if v == nil {
+			return nil, fmt.Errorf("incomplete station '%s'", k)
+		}
+		s.Stations[k] = v
+	}
+
+	c := &Checkpoint{Snapshot: s, done: j.Done, violating: make(map[string]bool, len(j.Violating))}
+	for _, e := range j.Errors {
+		if e.Time == nil {
+			return nil, fmt.Errorf("error without time")
+		}
+		c.errs = append(c.errs, &SimulationError{Time: *e.Time, Entity: e.Entity, ID: e.ID, Err: errors.New(e.Message)})
+	}
+	for _, k := range j.Violating {
+		c.violating[k] = true
+	}
+	return c, nil
+}
+
+// ReadCheckpointFile reads the checkpoint file at path.
+func ReadCheckpointFile(path string) (*Checkpoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadCheckpoint(f)
+}
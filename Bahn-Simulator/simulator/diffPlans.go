@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import "sort"
+
+// TrainActionChange is a difference between the plans of a train.
+// Old is nil for added actions, New is nil for removed actions. If both are set, the action moved in time.
+type TrainActionChange struct {
+	Train string
+	Old   *TrainAction
+	New   *TrainAction
+}
+
+// PassengerChange is a passenger with a different plan.
+type PassengerChange struct {
+	Passenger string
+	Old       []PassengerAction
+	New       []PassengerAction
+}
+
+// DiffPlans returns the differences between the plans of a and b, which must be created from the same input
+// and must not have been simulated.
+//
+// Train actions are matched by type and target in order of time. Matched actions at different times are reported
+// as moved. Changes are sorted by natural ID order and by time.
+func DiffPlans(a, b *World) ([]TrainActionChange, []PassengerChange) {
+	var trains []TrainActionChange
+	ids := make(map[string]bool, len(a.Trains))
+	for k := range a.Trains {
+		ids[k] = true
+	}
+	for k := range b.Trains {
+		ids[k] = true
+	}
+	for _, k := range sortedIDs(ids) {
+		var before, after []TrainAction
+		if t, ok := a.Trains[k]; ok {
+			before = trainPlan(t)
+		}
+		if t, ok := b.Trains[k]; ok {
+			after = trainPlan(t)
+		}
+		trains = append(trains, diffTrainPlans(k, before, after)...)
+	}
+
+	var passengers []PassengerChange
+	ids = make(map[string]bool, len(a.Passengers))
+	for k := range a.Passengers {
+		ids[k] = true
+	}
+	for k := range b.Passengers {
+		ids[k] = true
+	}
+	for _, k := range sortedIDs(ids) {
+		var before, after []PassengerAction
+		if p, ok := a.Passengers[k]; ok {
+			before = p.Plan
+		}
+		if p, ok := b.Passengers[k]; ok {
+			after = p.Plan
+		}
+		if !equalPassengerPlans(before, after) {
+			passengers = append(passengers, PassengerChange{Passenger: k, Old: before, New: after})
+		}
+	}
+	return trains, passengers
+}
+
+// sortedIDs returns the keys of ids in natural order.
+func sortedIDs(ids map[string]bool) []string {
+	s := make([]string, 0, len(ids))
+	for k := range ids {
+		s = append(s, k)
+	}
+	SortIDs(s)
+	return s
+}
+
+// diffTrainPlans matches the actions of both plans, which must be sorted by time.
+func diffTrainPlans(train string, before, after []TrainAction) []TrainActionChange {
+	type key struct {
+		t      TrainActionType
+		target string
+	}
+	// Position of the next unmatched action in after for each key
+	pending := make(map[key][]int)
+	for i := range after {
+		k := key{after[i].Type, after[i].Target}
+		pending[k] = append(pending[k], i)
+	}
+	matched := make([]bool, len(after))
+
+	var changes []TrainActionChange
+	for i := range before {
+		k := key{before[i].Type, before[i].Target}
+		if len(pending[k]) == 0 {
+			changes = append(changes, TrainActionChange{Train: train, Old: &before[i]})
+			continue
+		}
+		j := pending[k][0]
+		pending[k] = pending[k][1:]
+		matched[j] = true
+		if before[i].Time.Cmp(&after[j].Time) != 0 {
+			changes = append(changes, TrainActionChange{Train: train, Old: &before[i], New: &after[j]})
+		}
+	}
+	for j := range after {
+		if !matched[j] {
+			changes = append(changes, TrainActionChange{Train: train, New: &after[j]})
+		}
+	}
+
+	// Sort by the time of the old action, or the new action if there is none
+	time := func(c TrainActionChange) *Int {
+		if c.Old != nil {
+			return &c.Old.Time
+		}
+		return &c.New.Time
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return time(changes[i]).Cmp(time(changes[j])) == -1
+	})
+	return changes
+}
+
+func equalPassengerPlans(a, b []PassengerAction) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Type != b[i].Type || a[i].Train != b[i].Train || a[i].Time.Cmp(&b[i].Time) != 0 {
+			return false
+		}
+	}
+	return true
+}
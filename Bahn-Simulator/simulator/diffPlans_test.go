@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiffPlans(t *testing.T) {
+	input := "[Stations]\nS1 2\nS2 2\n[Lines]\nL1 S1 S2 1 1\n[Trains]\nT1 S1 1 5\nT2 * 1 5\n[Passengers]\nP1 S1 S2 1 3\nP2 S1 S2 1 3\n"
+	world := func(plan string) *World {
+		w, err := ParseInputReader(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ParsePlanReader(w, strings.NewReader(plan))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+	a := world("[Train:T1]\n2 Depart L1\n4 Depart L1\n[Train:T2]\n0 Start S1\n[Passenger:P1]\n1 Board T1\n3 Detrain\n[Passenger:P2]\n1 Board T1\n3 Detrain\n")
+	b := world("[Train:T1]\n3 Depart L1\n[Train:T2]\n0 Start S2\n[Passenger:P1]\n1 Board T1\n3 Detrain\n[Passenger:P2]\n2 Board T1\n4 Detrain\n")
+
+	trains, passengers := DiffPlans(a, b)
+	var got []string
+	for _, c := range trains {
+		old, new := "", ""
+		if c.Old != nil {
+			old = c.Old.String()
+		}
+		if c.New != nil {
+			new = c.New.String()
+		}
+		got = append(got, fmt.Sprintf("%s:%s/%s", c.Train, old, new))
+	}
+	want := "T1:2 Depart L1/3 Depart L1,T1:4 Depart L1/,T2:0 Start S1/,T2:/0 Start S2"
+	if strings.Join(got, ",") != want {
+		t.Errorf("wrong train changes: %s", strings.Join(got, ","))
+	}
+	if len(passengers) != 1 || passengers[0].Passenger != "P2" || passengers[0].New[0].Time.String() != "2" {
+		t.Errorf("wrong passenger changes: %+v", passengers)
+	}
+
+	trains, passengers = DiffPlans(a, a)
+	if len(trains) != 0 || len(passengers) != 0 {
+		t.Errorf("changes in equal plans: %+v %+v", trains, passengers)
+	}
+}
@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"sort"
+)
+
+// schedule determines the timesteps which have to be simulated.
+//
+// A timestep only needs to be simulated if an action is planned or a train arrives at a station.
+// In all other timesteps, trains on lines only move forward and nothing else changes,
+// so validation can not find new violations either.
+// The last timestep (MaxTime+1) is always simulated to match the tick by tick simulation.
+type schedule struct {
+	tickByTick bool
+	entries    []scheduleEntry
+	next       int
+}
+
+// scheduleEntry is a timestep with planned actions together with the passengers acting in it.
+type scheduleEntry struct {
+	time       *Int
+	passengers []*Passenger
+}
+
+func newSchedule(w *World, tickByTick bool) *schedule {
+	s := &schedule{tickByTick: tickByTick}
+	if tickByTick {
+		return s
+	}
+
+	for _, t := range w.Trains {
+		for i := range t.Plan {
+			s.entries = append(s.entries, scheduleEntry{time: &t.Plan[i].Time})
+		}
+	}
+	for _, p := range w.Passengers {
+		for i := range p.Plan {
+			s.entries = append(s.entries, scheduleEntry{time: &p.Plan[i].Time, passengers: []*Passenger{p}})
+		}
+	}
+	sort.SliceStable(s.entries, func(i, j int) bool { return s.entries[i].time.Cmp(s.entries[j].time) == -1 })
+
+	// Merge entries of the same time
+	merged := s.entries[:0]
+	for i := range s.entries {
+		if len(merged) != 0 && merged[len(merged)-1].time.Cmp(s.entries[i].time) == 0 {
+			merged[len(merged)-1].passengers = append(merged[len(merged)-1].passengers, s.entries[i].passengers...)
+			continue
+		}
+		merged = append(merged, s.entries[i])
+	}
+	s.entries = merged
+	return s
+}
+
+// nextTime returns the next timestep which has to be simulated.
+// It returns false if the simulation is finished.
+func (s *schedule) nextTime(w *World) (*Int, bool) {
+	if w.CurrentTime.Cmp(&w.MaxTime) == +1 {
+		return nil, false
+	}
+
+	next := new(Int).Add(&w.CurrentTime, NewInt(1))
+	if s.tickByTick {
+		return next, true
+	}
+
+	// Last timestep
+	next.Add(&w.MaxTime, NewInt(1))
+
+	for s.next < len(s.entries) && s.entries[s.next].time.Cmp(&w.CurrentTime) != +1 {
+		s.next++
+	}
+	if s.next < len(s.entries) && s.entries[s.next].time.Cmp(next) == -1 {
+		next.Set(s.entries[s.next].time)
+	}
+
+	for _, t := range w.Trains {
+		if t.PositionType != TrainPositionLine {
+			continue
+		}
+		ticks, ok := t.ticksToArrival(w)
+		if !ok {
+			// Let the simulation report the error in the next timestep
+			return new(Int).Add(&w.CurrentTime, NewInt(1)), true
+		}
+		ticks.Add(ticks, &w.CurrentTime)
+		if ticks.Cmp(next) == -1 {
+			next.Set(ticks)
+		}
+	}
+	return next, true
+}
+
+// passengersAt returns all passengers which have to be updated at the current time.
+func (s *schedule) passengersAt(w *World) []*Passenger {
+	if s.tickByTick {
+		passengers := make([]*Passenger, 0, len(w.Passengers))
+		for _, p := range w.Passengers {
+			passengers = append(passengers, p)
+		}
+		return passengers
+	}
+	if s.next < len(s.entries) && s.entries[s.next].time.Cmp(&w.CurrentTime) == 0 {
+		return s.entries[s.next].passengers
+	}
+	return nil
+}
+
+// skipTicks applies the effect of n timesteps in which the train does nothing but move on its line.
+// The timesteps must not contain an arrival at a station.
+func (t *Train) skipTicks(n *Int) {
+	if n.Sign() != +1 {
+		return
+	}
+	switch t.PositionType {
+	case TrainPositionStation:
+		t.BoardingPossible = true
+	case TrainPositionLine:
+		t.BoardingPossible = false
+		t.PositionSince.Add(&t.PositionSince, new(Rat).SetInt(n))
+	case TrainPositionWildcard:
+		t.BoardingPossible = false
+	}
+}
+
+// ticksToArrival returns the number of timesteps until a train on a line arrives at its target station.
+// It returns false if the line of the train is unknown.
+func (t *Train) ticksToArrival(w *World) (*Int, bool) {
+	if len(t.Position) != 2 {
+		return nil, false
+	}
+	line, ok := w.Lines[t.Position[0]]
+	if !ok || t.Speed.Sign() != +1 {
+		return nil, false
+	}
+	// The train arrives in the first timestep k with (PositionSince + k) * Speed >= Length,
+	// which is k >= (Length - PositionSince * Speed) / Speed
+	remaining := new(Rat).Mul(&t.PositionSince, &t.Speed)
+	remaining.Sub(&line.Length, remaining)
+	ticks := remaining.CeilQuo(&t.Speed)
+	if ticks.Cmp(NewInt(1)) == -1 {
+		ticks.SetInt64(1)
+	}
+	return ticks, true
+}
@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// runSummary runs a simulation and returns everything observable about it as a string.
+func runSummary(t *testing.T, input string, plan string, opt Options) string {
+	w, err := ParseInput(input)
+	if err != nil {
+		t.Fatal("can not read input:", err)
+	}
+	err = ParsePlanReader(w, strings.NewReader(plan))
+	if err != nil {
+		t.Fatal("can not read plan:", err)
+	}
+
+	var b strings.Builder
+	opt.Trace = func(e Event) {
+		fmt.Fprintln(&b, e.Time.String(), e.Type, e.Train, e.Passenger, e.Line, e.Station, e.Count.String())
+	}
+	result := w.Run(opt)
+	fmt.Fprintln(&b, result.Valid, result.Delay, result.EndTime)
+	for i := range result.Errors {
+		fmt.Fprintln(&b, result.Errors[i].Error())
+	}
+	for _, p := range result.Passengers {
+		fmt.Fprintln(&b, p.ID, p.Arrival, p.Delay, p.Legs)
+	}
+	return b.String()
+}
+
+func TestScheduleMatchesTickByTick(t *testing.T) {
+	type testCase struct {
+		name, input, plan string
+	}
+	var cases []testCase
+
+	dirs, err := os.ReadDir(path.Join("..", "test"))
+	if err != nil {
+		t.Fatal("can not read test dir:", err)
+	}
+	for i := range dirs {
+		if !dirs[i].IsDir() || skipLarge(dirs[i].Name()) {
+			continue
+		}
+		plan, err := os.ReadFile(path.Join("..", "test", dirs[i].Name(), "output.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases = append(cases, testCase{dirs[i].Name(), path.Join("..", "test", dirs[i].Name(), "input.txt"), string(plan)})
+	}
+	simple := path.Join("..", "test", "simple", "input.txt")
+	cases = append(cases,
+		testCase{"invalid", simple, "[Train:T1]\n1 Depart L1\n2 Depart L2\n\n[Train:T2]\n0 Start S2\n1 Depart L1\n3 Depart L1\n\n[Passenger:P1]\n1 Board T2\n6 Detrain\n\n[Passenger:P2]\n1 Board T1\n3 Detrain\n4 Board T3\n"},
+		testCase{"empty", simple, ""},
+		testCase{"late", simple, "[Train:T2]\n0 Start S2\n20 Depart L1\n\n[Passenger:P1]\n10 Board T2\n40 Detrain\n"},
+	)
+
+	for _, c := range cases {
+		for _, keepGoing := range []bool{false, true} {
+			if keepGoing && c.name == "large" {
+				// Valid plan, keep going does not change anything but doubles the runtime
+				continue
+			}
+			tick := runSummary(t, c.input, c.plan, Options{TickByTick: true, KeepGoing: keepGoing})
+			event := runSummary(t, c.input, c.plan, Options{KeepGoing: keepGoing})
+			if tick != event {
+				t.Errorf("%s (keep going: %v): results differ\ntick by tick:\n%s\nscheduled:\n%s", c.name, keepGoing, tick, event)
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"sort"
+	"sync"
+)
+
+type EventType int
+
+const (
+	EventUnknown EventType = iota
+	EventTrainStart
+	EventTrainDepart
+	EventTrainArrive
+	EventPassengerBoard
+	EventPassengerDetrain
+	EventTargetReached
+	EventLineCapacity
+	EventStationCapacity
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventTrainStart:
+		return "start"
+	case EventTrainDepart:
+		return "depart"
+	case EventTrainArrive:
+		return "arrive"
+	case EventPassengerBoard:
+		return "board"
+	case EventPassengerDetrain:
+		return "detrain"
+	case EventTargetReached:
+		return "target_reached"
+	case EventLineCapacity:
+		return "line_capacity"
+	case EventStationCapacity:
+		return "station_capacity"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a single change of the world during a simulation.
+// Only the fields relevant for the type are set.
+// For EventLineCapacity and EventStationCapacity, Count is the number of trains on the line or at the station
+// after the change and Capacity is the maximum capacity.
+type Event struct {
+	Time      Int
+	Type      EventType
+	Train     string
+	Passenger string
+	Line      string
+	Station   string
+	Count     Int
+	Capacity  Int
+
+	// entity and id of the source of the event, used for ordering events of the same timestep
+	entity Entity
+	id     string
+	// delta is the change of the count for capacity events
+	delta int64
+}
+
+// eventRecorder collects the events of a timestep from all concurrently running updates.
+type eventRecorder struct {
+	l      sync.Mutex
+	events []Event
+}
+
+// record adds an event at the current time of w. Nothing is recorded if w does not record events.
+func (w *World) record(entity Entity, id string, e Event) {
+	if w.recorder == nil {
+		return
+	}
+	e.Time.Set(&w.CurrentTime)
+	e.entity = entity
+	e.id = id
+	w.recorder.l.Lock()
+	w.recorder.events = append(w.recorder.events, e)
+	w.recorder.l.Unlock()
+}
+
+// recordLineCapacity records that the number of trains on l changed by delta.
+// The resulting count is calculated in flushEvents so that it does not depend on the order of concurrent updates.
+func (w *World) recordLineCapacity(entity Entity, id string, l *Line, delta int64) {
+	if w.recorder == nil {
+		return
+	}
+	e := Event{Type: EventLineCapacity, Line: l.ID, delta: delta}
+	e.Capacity.Set(&l.MaxCapacity)
+	w.record(entity, id, e)
+}
+
+// recordStationCapacity records that the number of trains at s changed by delta.
+// The resulting count is calculated in flushEvents so that it does not depend on the order of concurrent updates.
+func (w *World) recordStationCapacity(entity Entity, id string, s *Station, delta int64) {
+	if w.recorder == nil {
+		return
+	}
+	e := Event{Type: EventStationCapacity, Station: s.ID, delta: delta}
+	e.Capacity.Set(&s.Capacity)
+	w.record(entity, id, e)
+}
+
+// flushEvents passes all recorded events to f in a deterministic order and clears the recorder.
+// Events are ordered by time, by the entity (trains before passengers) and id of their source.
+// Events of a single source keep the order in which they happened.
+func (w *World) flushEvents(f func(Event)) {
+	if w.recorder == nil {
+		return
+	}
+	w.recorder.l.Lock()
+	events := w.recorder.events
+	w.recorder.events = nil
+	w.recorder.l.Unlock()
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if c := events[i].Time.Cmp(&events[j].Time); c != 0 {
+			return c < 0
+		}
+		if events[i].entity != events[j].entity {
+			return events[i].entity < events[j].entity
+		}
+		return CompareIDs(events[i].id, events[j].id) < 0
+	})
+
+	// Calculate the counts before the recorded changes from the current state
+	lineCount := make(map[string]*Int)
+	stationCount := make(map[string]*Int)
+	for i := range events {
+		switch events[i].Type {
+		case EventLineCapacity:
+			c, ok := lineCount[events[i].Line]
+			if !ok {
+				c = new(Int).Set(&w.Lines[events[i].Line].CurrentCapacity)
+				lineCount[events[i].Line] = c
+			}
+			c.Sub(c, NewInt(events[i].delta))
+		case EventStationCapacity:
+			c, ok := stationCount[events[i].Station]
+			if !ok {
+				c = new(Int).Set(&w.Stations[events[i].Station].CurrenTrains)
+				stationCount[events[i].Station] = c
+			}
+			c.Sub(c, NewInt(events[i].delta))
+		}
+	}
+
+	for i := range events {
+		switch events[i].Type {
+		case EventLineCapacity:
+			c := lineCount[events[i].Line]
+			c.Add(c, NewInt(events[i].delta))
+			events[i].Count.Set(c)
+		case EventStationCapacity:
+			c := stationCount[events[i].Station]
+			c.Add(c, NewInt(events[i].delta))
+			events[i].Count.Set(c)
+		}
+		f(events[i])
+	}
+}
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// FormatPlan reads a plan from r into w and writes it in canonical form to out.
+//
+// The canonical form is the output of WritePlan. Comments are kept in front of the section header or action
+// they precede. Comments in front of the first section stay at the top, all other comments which lose their
+// anchor (e.g. because the section is empty) are moved to the end.
+// Parsing errors are returned as ParseErrors, nothing is written in this case.
+func FormatPlan(w *World, r io.Reader, out io.Writer) error {
+	var plan bytes.Buffer
+	_, err := plan.ReadFrom(r)
+	if err != nil {
+		return err
+	}
+	err = ParsePlanReader(w, bytes.NewReader(plan.Bytes()))
+	if err != nil {
+		return err
+	}
+	c, err := readPlanComments(bytes.NewReader(plan.Bytes()))
+	if err != nil {
+		return err
+	}
+	return writePlan(w, out, c)
+}
+
+// planComments contains comments of a plan by their anchor.
+//
+// The anchor of a section header is the header, the anchor of an action is the header of its section followed by
+// a space and the time of the action. Comments in front of the first section use the empty anchor.
+type planComments struct {
+	anchors map[string][]string
+	// order contains all anchors in order of their first appearance
+	order []string
+	// trailing contains comments after the last action
+	trailing []string
+}
+
+// readPlanComments collects the comments of a plan. The plan must be valid.
+func readPlanComments(r io.Reader) (*planComments, error) {
+	c := &planComments{anchors: make(map[string][]string)}
+	var pending []string
+	header := ""
+	anchor := func(key string) {
+		if len(pending) == 0 {
+			return
+		}
+		if _, ok := c.anchors[key]; !ok {
+			c.order = append(c.order, key)
+		}
+		c.anchors[key] = append(c.anchors[key], pending...)
+		pending = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		s := scanner.Text()
+		switch {
+		case strings.HasPrefix(s, "#"):
+			pending = append(pending, strings.TrimRightFunc(s, unicode.IsSpace))
+		case s == "":
+		case strings.HasPrefix(s, "["):
+			if header == "" {
+				// Comments in front of the first section belong to the whole file
+				anchor("")
+			}
+			header = strings.TrimSpace(s)
+			anchor(header)
+		default:
+			fields := strings.Fields(s)
+			time, ok := new(Int).SetString(fields[0], 10)
+			if ok {
+				anchor(header + " " + time.String())
+			}
+		}
+	}
+	c.trailing = pending
+	return c, scanner.Err()
+}
+
+// write writes and removes the comments of anchor. It returns whether comments were written.
+func (c *planComments) write(b *bufio.Writer, anchor string) bool {
+	if c == nil || len(c.anchors[anchor]) == 0 {
+		return false
+	}
+	for _, s := range c.anchors[anchor] {
+		b.WriteString(s + "\n")
+	}
+	delete(c.anchors, anchor)
+	return true
+}
+
+// remaining returns whether comments are left.
+func (c *planComments) remaining() bool {
+	return c != nil && (len(c.anchors) != 0 || len(c.trailing) != 0)
+}
+
+// writeRemaining writes all comments left in order of their anchors, followed by the trailing comments.
+func (c *planComments) writeRemaining(b *bufio.Writer) {
+	if c == nil {
+		return
+	}
+	for _, anchor := range c.order {
+		for _, s := range c.anchors[anchor] {
+			b.WriteString(s + "\n")
+		}
+		delete(c.anchors, anchor)
+	}
+	for _, s := range c.trailing {
+		b.WriteString(s + "\n")
+	}
+	c.trailing = nil
+}
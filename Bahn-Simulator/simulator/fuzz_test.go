@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Run a fuzz target with the race detector, e.g.
+//
+//	go test -race -run '^$' -fuzz '^FuzzSimulate$' -fuzzminimizetime 100x ./simulator
+//
+// Limiting -fuzzminimizetime keeps the fuzzer from stalling while minimizing large new inputs.
+// Inputs failing a target are written to testdata/fuzz/<target>/ and are run as regression cases by 'go test'.
+
+package simulator
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// maxFuzzSize limits the size of fuzzed documents to keep single runs fast.
+const maxFuzzSize = 4096
+
+// addTestCorpus adds input and plan of all test cases in test/ except large to the seed corpus.
+func addTestCorpus(f *testing.F) {
+	dirs, err := os.ReadDir(path.Join("..", "test"))
+	if err != nil {
+		f.Fatal("can not read test dir:", err)
+	}
+	for i := range dirs {
+		if !dirs[i].IsDir() || dirs[i].Name() == "large" {
+			continue
+		}
+		input, err := os.ReadFile(path.Join("..", "test", dirs[i].Name(), "input.txt"))
+		if err != nil {
+			f.Fatal(err)
+		}
+		plan, err := os.ReadFile(path.Join("..", "test", dirs[i].Name(), "output.txt"))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(input, plan)
+	}
+
+	// Edge cases not covered by test/
+	input := "[Stations]\nS1 1\nS2 1\n[Lines]\nL1 S1 S2 1 1\n[Trains]\nT1 * 1 10\n[Passengers]\nP1 S1 S2 1 3\n"
+	for _, plan := range []string{
+		"[Train:T1]\n0 Start S1\n0 Start S2\n",
+		"[Train:T1]\n0 Start S1\n1 Depart L1\n[Passenger:P1]\n1 Board T1\n2 Detrain\n",
+		"[Train:T2]\n0 Start S1\n[Passenger:P2]\n1 Board T3\n",
+		"[Train:T1]\n-1 Start S1\n00 Depart L1\n",
+	} {
+		f.Add([]byte(input), []byte(plan))
+	}
+	f.Add([]byte("[Stations]\nS1 0\nS2 -1\n[Lines]\nL1 S1 S2 0 0\n[Trains]\nT1 S1 0 0\n[Passengers]\nP1 S1 S1 0 0\n"), []byte(""))
+}
+
+// parseFuzzed parses input and plan. It returns nil if either can not be parsed.
+func parseFuzzed(input, plan []byte) *World {
+	w, err := ParseInputReader(bytes.NewReader(input))
+	if err != nil {
+		return nil
+	}
+	if ParsePlanReader(w, bytes.NewReader(plan)) != nil {
+		return nil
+	}
+	return w
+}
+
+// verdict summarises a result for comparisons between runs.
+func verdict(r Result) string {
+	var b strings.Builder
+	if r.Valid {
+		b.WriteString("valid ")
+	}
+	if r.Delay != nil {
+		b.WriteString(r.Delay.String())
+	}
+	for i := range r.Errors {
+		b.WriteString("\n" + r.Errors[i].Error())
+	}
+	return b.String()
+}
+
+func FuzzParseInput(f *testing.F) {
+	addTestCorpus(f)
+	f.Fuzz(func(t *testing.T, input, _ []byte) {
+		if len(input) > maxFuzzSize {
+			return
+		}
+		w, err := ParseInputReader(bytes.NewReader(input))
+		_, errAgain := ParseInputReader(bytes.NewReader(input))
+		if (err == nil) != (errAgain == nil) || (err != nil && err.Error() != errAgain.Error()) {
+			t.Fatalf("different verdicts: %v, %v", err, errAgain)
+		}
+		if err != nil {
+			return
+		}
+		w.ValidateStart()
+		w.CheckConnected()
+	})
+}
+
+func FuzzParsePlan(f *testing.F) {
+	addTestCorpus(f)
+	f.Fuzz(func(t *testing.T, input, plan []byte) {
+		if len(input) > maxFuzzSize || len(plan) > maxFuzzSize {
+			return
+		}
+		w, err := ParseInputReader(bytes.NewReader(input))
+		if err != nil {
+			return
+		}
+		err = ParsePlanReader(w, bytes.NewReader(plan))
+		w, _ = ParseInputReader(bytes.NewReader(input))
+		errAgain := ParsePlanReader(w, bytes.NewReader(plan))
+		if (err == nil) != (errAgain == nil) || (err != nil && err.Error() != errAgain.Error()) {
+			t.Fatalf("different verdicts: %v, %v", err, errAgain)
+		}
+	})
+}
+
+func FuzzSimulate(f *testing.F) {
+	addTestCorpus(f)
+	f.Fuzz(func(t *testing.T, input, plan []byte) {
+		if len(input) > maxFuzzSize || len(plan) > maxFuzzSize {
+			return
+		}
+		var verdicts []string
+		for _, opt := range []Options{{}, {}, {KeepGoing: true}, {KeepGoing: true}} {
+			w := parseFuzzed(input, plan)
+			if w == nil {
+				return
+			}
+			verdicts = append(verdicts, verdict(w.Run(opt)))
+		}
+		if verdicts[0] != verdicts[1] || verdicts[2] != verdicts[3] {
+			t.Fatalf("different verdicts from repeated runs:\n%s\n---\n%s\n---\n%s\n---\n%s", verdicts[0], verdicts[1], verdicts[2], verdicts[3])
+		}
+	})
+}
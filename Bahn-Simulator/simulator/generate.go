@@ -0,0 +1,416 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// Topologies supported by Generate.
+const (
+	TopologyGrid      = "grid"
+	TopologyRing      = "ring"
+	TopologyTree      = "tree"
+	TopologyGeometric = "geometric"
+	TopologyHub       = "hub"
+)
+
+// Topologies contains all topologies supported by Generate.
+var Topologies = []string{TopologyGrid, TopologyRing, TopologyTree, TopologyGeometric, TopologyHub}
+
+// MaxGenerateValue is the largest value allowed in the ranges of GenerateOptions.
+// It keeps the random values (in hundredths for decimals) within int64.
+const MaxGenerateValue = 1e12
+
+// Range is an inclusive range of values. Integer ranges only use the integer part.
+type Range struct {
+	Min, Max float64
+}
+
+// ParseRange parses "min-max" or a single value.
+func ParseRange(s string) (Range, bool) {
+	for i := 1; i < len(s); i++ {
+		if s[i] != '-' {
+			continue
+		}
+		min, err1 := strconv.ParseFloat(s[:i], 64)
+		max, err2 := strconv.ParseFloat(s[i+1:], 64)
+		if err1 != nil || err2 != nil || min > max {
+			return Range{}, false
+		}
+		return Range{min, max}, true
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return Range{}, false
+	}
+	return Range{v, v}, true
+}
+
+// Set parses s like ParseRange, so that a *Range can be used as a flag.Value.
+func (r *Range) Set(s string) error {
+	v, ok := ParseRange(s)
+	if !ok {
+		return fmt.Errorf("invalid range '%s', expected 'min-max' or a single value", s)
+	}
+	*r = v
+	return nil
+}
+
+func (r Range) String() string {
+	if r.Min == r.Max {
+		return strconv.FormatFloat(r.Min, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(r.Min, 'f', -1, 64) + "-" + strconv.FormatFloat(r.Max, 'f', -1, 64)
+}
+
+// GenerateOptions controls the instances created by Generate. Values are drawn uniformly from all ranges.
+type GenerateOptions struct {
+	Seed     int64
+	Topology string
+	Stations int
+	// Lines is the total number of lines. Lines exceeding the lines of the topology connect random stations
+	// (the nearest ones for geometric networks). 0 only creates the lines of the topology.
+	Lines int
+	// Trains is the number of trains, of which a share of Wildcards (0 to 1) starts at '*'.
+	Trains     int
+	Wildcards  float64
+	Passengers int
+
+	StationCapacity Range
+	LineCapacity    Range
+	// LineLength is the length of the lines. In geometric networks, it scales with the distance of the stations.
+	LineLength    Range
+	TrainSpeed    Range
+	TrainCapacity Range
+	GroupSize     Range
+	TargetTime    Range
+}
+
+// DefaultGenerateOptions returns options for a small instance.
+func DefaultGenerateOptions() GenerateOptions {
+	return GenerateOptions{
+		Seed:            1,
+		Topology:        TopologyGrid,
+		Stations:        16,
+		Trains:          4,
+		Wildcards:       0.25,
+		Passengers:      20,
+		StationCapacity: Range{1, 3},
+		LineCapacity:    Range{1, 2},
+		LineLength:      Range{1, 10},
+		TrainSpeed:      Range{0.5, 3},
+		TrainCapacity:   Range{10, 50},
+		GroupSize:       Range{1, 10},
+		TargetTime:      Range{5, 50},
+	}
+}
+
+// Generate creates a random world without plans. The network is connected and the world passes ValidateStart.
+// The same options always create the same world.
+func Generate(opt GenerateOptions) (*World, error) {
+	switch {
+	case opt.Stations < 2:
+		return nil, fmt.Errorf("at least 2 stations are needed")
+	case opt.Trains < 0 || opt.Passengers < 0 || opt.Lines < 0:
+		return nil, fmt.Errorf("numbers must not be negative")
+	case opt.Wildcards < 0 || opt.Wildcards > 1:
+		return nil, fmt.Errorf("share of wildcard trains must be between 0 and 1")
+	case opt.StationCapacity.Min < 1 || opt.LineCapacity.Min < 1 || opt.GroupSize.Min < 1 || opt.TargetTime.Min < 1 || opt.TrainCapacity.Min < 0:
+		return nil, fmt.Errorf("capacities, group sizes and target times must be positive")
+	case opt.LineLength.Min <= 0 || opt.TrainSpeed.Min <= 0:
+		return nil, fmt.Errorf("lengths and speeds must be positive")
+	}
+	for _, r := range []Range{opt.StationCapacity, opt.LineCapacity, opt.LineLength, opt.TrainSpeed, opt.TrainCapacity, opt.GroupSize, opt.TargetTime} {
+		// Also catches NaN and infinity
+		if !(r.Max <= MaxGenerateValue) {
+			return nil, fmt.Errorf("values must not be larger than %g", float64(MaxGenerateValue))
+		}
+	}
+
+	g := &generator{opt: opt, r: rand.New(rand.NewSource(opt.Seed)), pairs: make(map[[2]int]bool)}
+	switch opt.Topology {
+	case TopologyGrid:
+		g.grid()
+	case TopologyRing:
+		g.ring()
+	case TopologyTree:
+		g.tree()
+	case TopologyGeometric:
+		g.geometric()
+	case TopologyHub:
+		g.hub()
+	default:
+		return nil, fmt.Errorf("unknown topology '%s'", opt.Topology)
+	}
+	if opt.Lines != 0 && opt.Lines < len(g.lines) {
+		return nil, fmt.Errorf("topology %s with %d stations needs at least %d lines", opt.Topology, opt.Stations, len(g.lines))
+	}
+	if opt.Lines > opt.Stations*(opt.Stations-1)/2 {
+		return nil, fmt.Errorf("at most %d lines fit between %d stations", opt.Stations*(opt.Stations-1)/2, opt.Stations)
+	}
+	g.extraLines()
+
+	w := &World{
+		Lines:      make(map[string]*Line),
+		Stations:   make(map[string]*Station),
+		Trains:     make(map[string]*Train),
+		Passengers: make(map[string]*Passenger),
+	}
+	stations := make([]*Station, opt.Stations)
+	for i := range stations {
+		stations[i] = &Station{ID: "S" + strconv.Itoa(i+1)}
+		stations[i].Capacity.SetInt64(g.int(opt.StationCapacity))
+		w.Stations[stations[i].ID] = stations[i]
+	}
+	for i, pair := range g.lines {
+		l := &Line{ID: "L" + strconv.Itoa(i+1), End: []string{stations[pair[0]].ID, stations[pair[1]].ID}}
+		l.MaxCapacity.SetInt64(g.int(opt.LineCapacity))
+		l.Length = g.length(pair)
+		w.Lines[l.ID] = l
+	}
+
+	err := g.trains(w, stations)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < opt.Passengers; i++ {
+		p := &Passenger{ID: "P" + strconv.Itoa(i+1), PositionType: PassengerPositionStation}
+		start := g.r.Intn(len(stations))
+		target := g.r.Intn(len(stations) - 1)
+		if target >= start {
+			target++
+		}
+		p.Start, p.Target, p.Position = stations[start].ID, stations[target].ID, stations[start].ID
+		p.Size.SetInt64(g.int(opt.GroupSize))
+		p.TargetTime.SetInt64(g.int(opt.TargetTime))
+		w.Passengers[p.ID] = p
+	}
+	w.network = NewNetwork(w)
+	return w, nil
+}
+
+// generator contains the state of Generate.
+type generator struct {
+	opt GenerateOptions
+	r   *rand.Rand
+	// lines contains the stations of all lines by index
+	lines [][2]int
+	pairs map[[2]int]bool
+	// points contains the coordinates of stations in geometric networks
+	points [][2]float64
+}
+
+// connect adds a line between a and b. It returns false if both are already connected.
+func (g *generator) connect(a, b int) bool {
+	if a == b {
+		return false
+	}
+	key := [2]int{a, b}
+	if a > b {
+		key = [2]int{b, a}
+	}
+	if g.pairs[key] {
+		return false
+	}
+	g.pairs[key] = true
+	g.lines = append(g.lines, [2]int{a, b})
+	return true
+}
+
+func (g *generator) grid() {
+	n := g.opt.Stations
+	width := int(math.Ceil(math.Sqrt(float64(n))))
+	for i := 0; i < n; i++ {
+		if (i+1)%width != 0 && i+1 < n {
+			g.connect(i, i+1)
+		}
+		if i+width < n {
+			g.connect(i, i+width)
+		}
+	}
+}
+
+func (g *generator) ring() {
+	n := g.opt.Stations
+	for i := 0; i < n; i++ {
+		g.connect(i, (i+1)%n)
+	}
+}
+
+func (g *generator) tree() {
+	for i := 1; i < g.opt.Stations; i++ {
+		g.connect(g.r.Intn(i), i)
+	}
+}
+
+// geometric places stations randomly in the unit square and connects them by a minimum spanning tree.
+func (g *generator) geometric() {
+	n := g.opt.Stations
+	g.points = make([][2]float64, n)
+	for i := range g.points {
+		g.points[i] = [2]float64{g.r.Float64(), g.r.Float64()}
+	}
+
+	// Prim's algorithm on the complete graph
+	in := make([]bool, n)
+	best := make([]float64, n)
+	from := make([]int, n)
+	for i := range best {
+		best[i] = math.Inf(1)
+	}
+	best[0] = 0
+	for k := 0; k < n; k++ {
+		next := -1
+		for i := 0; i < n; i++ {
+			if !in[i] && (next == -1 || best[i] < best[next]) {
+				next = i
+			}
+		}
+		in[next] = true
+		if next != 0 {
+			g.connect(from[next], next)
+		}
+		for i := 0; i < n; i++ {
+			if d := g.distance(next, i); !in[i] && d < best[i] {
+				best[i], from[i] = d, next
+			}
+		}
+	}
+}
+
+// hub connects every station to one of the hubs, which form a ring.
+// There are at least 2 hubs so that no single station carries all traffic, 2 hubs are connected by a single line.
+func (g *generator) hub() {
+	n := g.opt.Stations
+	hubs := int(math.Sqrt(float64(n)) / 2)
+	if hubs < 2 {
+		hubs = 2
+	}
+	for i := 0; i < hubs; i++ {
+		g.connect(i, (i+1)%hubs)
+	}
+	for i := hubs; i < n; i++ {
+		g.connect(g.r.Intn(hubs), i)
+	}
+}
+
+func (g *generator) distance(a, b int) float64 {
+	return math.Hypot(g.points[a][0]-g.points[b][0], g.points[a][1]-g.points[b][1])
+}
+
+// extraLines adds lines until opt.Lines is reached.
+func (g *generator) extraLines() {
+	missing := g.opt.Lines - len(g.lines)
+	if missing <= 0 {
+		return
+	}
+	if g.points != nil {
+		// Connect the nearest stations not yet connected
+		n := g.opt.Stations
+		var candidates [][2]int
+		for a := 0; a < n; a++ {
+			for b := a + 1; b < n; b++ {
+				if !g.pairs[[2]int{a, b}] {
+					candidates = append(candidates, [2]int{a, b})
+				}
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return g.distance(candidates[i][0], candidates[i][1]) < g.distance(candidates[j][0], candidates[j][1])
+		})
+		for i := 0; i < missing; i++ {
+			g.connect(candidates[i][0], candidates[i][1])
+		}
+		return
+	}
+	for missing > 0 {
+		if g.connect(g.r.Intn(g.opt.Stations), g.r.Intn(g.opt.Stations)) {
+			missing--
+		}
+	}
+}
+
+// int returns a random integer in r.
+func (g *generator) int(r Range) int64 {
+	min, max := int64(r.Min), int64(r.Max)
+	if max <= min {
+		return min
+	}
+	return min + g.r.Int63n(max-min+1)
+}
+
+// decimal returns a random value in r with two decimal places. It is at least 0.01.
+func (g *generator) decimal(r Range) Rat {
+	min, max := int64(math.Ceil(r.Min*100)), int64(math.Floor(r.Max*100))
+	v := min
+	if max > min {
+		v += g.r.Int63n(max - min + 1)
+	}
+	if v < 1 {
+		v = 1
+	}
+	return *NewRat(v, 100)
+}
+
+// length returns the length of a line between the stations of pair.
+func (g *generator) length(pair [2]int) Rat {
+	if g.points == nil {
+		return g.decimal(g.opt.LineLength)
+	}
+	// The longest possible distance in the unit square is sqrt(2)
+	d := g.distance(pair[0], pair[1]) / math.Sqrt2
+	v := g.opt.LineLength.Min + d*(g.opt.LineLength.Max-g.opt.LineLength.Min)
+	return g.decimal(Range{v, v})
+}
+
+// trains adds all trains. Trains not starting at '*' are placed at random stations with space left.
+func (g *generator) trains(w *World, stations []*Station) error {
+	wildcards := int(math.Round(g.opt.Wildcards * float64(g.opt.Trains)))
+	var free []*Station
+	for _, st := range stations {
+		for i := int64(0); i < st.Capacity.Int64(); i++ {
+			free = append(free, st)
+		}
+	}
+	if g.opt.Trains-wildcards > len(free) {
+		return fmt.Errorf("%d trains do not fit into stations with a capacity of %d", g.opt.Trains-wildcards, len(free))
+	}
+	for i := 0; i < g.opt.Trains; i++ {
+		t := &Train{ID: "T" + strconv.Itoa(i+1)}
+		t.Speed = g.decimal(g.opt.TrainSpeed)
+		t.Capacity.SetInt64(g.int(g.opt.TrainCapacity))
+		if i < wildcards {
+			t.Position = []string{"*"}
+			t.PositionType = TrainPositionWildcard
+			t.Wildcard = true
+		} else {
+			j := g.r.Intn(len(free))
+			st := free[j]
+			free[j] = free[len(free)-1]
+			free = free[:len(free)-1]
+			t.Position = []string{st.ID}
+			t.PositionType = TrainPositionStation
+			st.CurrenTrains.Add(&st.CurrenTrains, NewInt(1))
+		}
+		w.Trains[t.ID] = t
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	for _, topology := range Topologies {
+		for _, stations := range []int{2, 3, 17, 50} {
+			opt := DefaultGenerateOptions()
+			opt.Topology = topology
+			opt.Stations = stations
+			opt.Lines = 2 * stations
+			if max := stations * (stations - 1) / 2; opt.Lines > max {
+				opt.Lines = max
+			}
+			opt.Seed = int64(stations)
+
+			var first []byte
+			for run := 0; run < 2; run++ {
+				w, err := Generate(opt)
+				if err != nil {
+					t.Fatalf("%s/%d: %v", topology, stations, err)
+				}
+				var b bytes.Buffer
+				err = WriteInput(w, &b)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if run == 1 {
+					if !bytes.Equal(first, b.Bytes()) {
+						t.Errorf("%s/%d: same seed created different inputs", topology, stations)
+					}
+					break
+				}
+				first = b.Bytes()
+
+				parsed, err := ParseInputReader(bytes.NewReader(b.Bytes()))
+				if err != nil {
+					t.Fatalf("%s/%d: can not parse generated input: %v", topology, stations, err)
+				}
+				if errs := parsed.ValidateStart(); len(errs) != 0 {
+					t.Errorf("%s/%d: invalid start: %v", topology, stations, errs)
+				}
+				if !parsed.CheckConnected() {
+					t.Errorf("%s/%d: network not connected", topology, stations)
+				}
+				if len(parsed.Lines) != opt.Lines {
+					t.Errorf("%s/%d: expected %d lines, got %d", topology, stations, opt.Lines, len(parsed.Lines))
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateSolvable(t *testing.T) {
+	opt := DefaultGenerateOptions()
+	opt.Topology = TopologyGeometric
+	// The greedy solver can get stuck at crowded stations
+	opt.StationCapacity = Range{3, 5}
+	opt.LineCapacity = Range{2, 3}
+	w, err := Generate(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	err = WriteInput(w, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := solveAndRun(t, b.String())
+	if !r.Valid {
+		t.Errorf("solved plan not valid: %v", r.Errors)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for name, change := range map[string]func(*GenerateOptions){
+		"topology":  func(o *GenerateOptions) { o.Topology = "star" },
+		"lines":     func(o *GenerateOptions) { o.Lines = 3 },
+		"too many":  func(o *GenerateOptions) { o.Lines = 1000 },
+		"trains":    func(o *GenerateOptions) { o.Trains, o.Wildcards = 100, 0 },
+		"wildcards": func(o *GenerateOptions) { o.Wildcards = 2 },
+		"range":     func(o *GenerateOptions) { o.TargetTime = Range{1, 1e19} },
+		"infinity":  func(o *GenerateOptions) { o.LineLength = Range{1, math.Inf(1)} },
+	} {
+		opt := DefaultGenerateOptions()
+		change(&opt)
+		if _, err := Generate(opt); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
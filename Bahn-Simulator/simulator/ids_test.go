@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortIDs(t *testing.T) {
+	ids := []string{"T10", "P1", "T2", "T1", "T02", "T", "T1a", "S100", "S99"}
+	SortIDs(ids)
+	expected := []string{"P1", "S99", "S100", "T", "T1", "T1a", "T02", "T2", "T10"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("wrong order: expected %v, got %v", expected, ids)
+	}
+}
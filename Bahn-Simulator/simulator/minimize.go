@@ -0,0 +1,392 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var classToken = regexp.MustCompile(`[^\s'(),:\[\]]+`)
+
+// ErrorClass returns the kind of rule violation described by err. IDs of w and numbers are replaced by '_',
+// so violations of the same rule by different entities or at different times have the same class.
+func ErrorClass(w *World, err error) string {
+	prefix := ""
+	var serr *SimulationError
+	if errors.As(err, &serr) {
+		prefix = serr.Entity.String() + ": "
+		err = serr.Err
+	}
+	return prefix + classToken.ReplaceAllStringFunc(err.Error(), func(s string) string {
+		if w.Stations[s] != nil || w.Lines[s] != nil || w.Trains[s] != nil || w.Passengers[s] != nil {
+			return "_"
+		}
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return "_"
+		}
+		return s
+	})
+}
+
+// Minimize removes passengers and trains together with their plans, train and passenger actions, lines and stations
+// from input and plan as long as the first error of the simulation keeps its ErrorClass.
+// Stations are removed together with their lines, so that the network can shrink while it stays connected.
+// The returned input and plan are written by WriteInput and WritePlan.
+// Progress is written to verbose if not nil.
+//
+// An error is returned if input or plan can not be parsed or the plan is valid.
+func Minimize(input, plan []byte, verbose io.Writer) ([]byte, []byte, string, error) {
+	w, err := ParseInputReader(bytes.NewReader(input))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	err = ParsePlanReader(w, bytes.NewReader(plan))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	var in, out bytes.Buffer
+	err = WriteInput(w, &in)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	err = WritePlan(w, &out)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	r := w.Run(Options{})
+	if r.Valid {
+		return nil, nil, "", fmt.Errorf("plan is valid")
+	}
+
+	m := &minimizer{
+		class:   ErrorClass(w, r.Errors[0]),
+		input:   splitSections(in.Bytes()),
+		plan:    splitSections(out.Bytes()),
+		verbose: verbose,
+	}
+	if m.verbose != nil {
+		fmt.Fprintln(m.verbose, "minimizing", m.class)
+	}
+
+	// Actions after the first error can not change it
+	var serr *SimulationError
+	if errors.As(r.Errors[0], &serr) {
+		m.truncate(&serr.Time)
+	}
+
+	// Whole entities are removed before single actions, stations last as they are used by everything else
+	for changed := true; changed; {
+		changed = m.reduceEntities("[Passengers]", "[Passenger:")
+		changed = m.reduceEntities("[Trains]", "[Train:") || changed
+		for _, header := range []string{"[Lines]", "[Stations]", "[Train:", "[Passenger:"} {
+			for _, sec := range append(append([]*section{}, m.input...), m.plan...) {
+				if strings.HasPrefix(sec.header, header) {
+					changed = m.reduceLines(sec, header == "[Lines]" || header == "[Stations]") || changed
+				}
+			}
+		}
+		changed = m.reduceNetwork() || changed
+	}
+
+	// Rewrite to drop empty plans
+	w, err = ParseInputReader(bytes.NewReader(joinSections(m.input)))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	err = ParsePlanReader(w, bytes.NewReader(joinSections(m.plan)))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	in.Reset()
+	out.Reset()
+	err = WriteInput(w, &in)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	err = WritePlan(w, &out)
+	return in.Bytes(), out.Bytes(), m.class, err
+}
+
+// section is a header of an input or plan file with the lines following it.
+type section struct {
+	header string
+	lines  []string
+}
+
+// splitSections splits a file written by WriteInput or WritePlan into sections.
+func splitSections(b []byte) []*section {
+	var sections []*section
+	for _, line := range strings.Split(string(b), "\n") {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "["):
+			sections = append(sections, &section{header: line})
+		default:
+			sections[len(sections)-1].lines = append(sections[len(sections)-1].lines, line)
+		}
+	}
+	return sections
+}
+
+// joinSections is the inverse of splitSections. Sections without lines are only kept if they are input sections.
+func joinSections(sections []*section) []byte {
+	var b bytes.Buffer
+	for _, s := range sections {
+		if len(s.lines) == 0 && strings.Contains(s.header, ":") {
+			continue
+		}
+		if b.Len() != 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(s.header + "\n")
+		for _, l := range s.lines {
+			b.WriteString(l + "\n")
+		}
+	}
+	return b.Bytes()
+}
+
+// minimizer contains the state of Minimize.
+type minimizer struct {
+	class   string
+	input   []*section
+	plan    []*section
+	runs    int
+	verbose io.Writer
+}
+
+// reproduces returns whether the current input and plan still fail with the class of the original error.
+func (m *minimizer) reproduces() bool {
+	m.runs++
+	w, err := ParseInputReader(bytes.NewReader(joinSections(m.input)))
+	if err != nil {
+		return false
+	}
+	err = ParsePlanReader(w, bytes.NewReader(joinSections(m.plan)))
+	if err != nil {
+		return false
+	}
+	r := w.Run(Options{})
+	return !r.Valid && len(r.Errors) != 0 && ErrorClass(w, r.Errors[0]) == m.class
+}
+
+// ddmin removes as many of n elements as possible. remove(start, end) removes the elements in [start, end)
+// and returns a function restoring them.
+// It returns whether anything was removed.
+func (m *minimizer) ddmin(n int, remove func(start, end int) func()) bool {
+	removed := false
+	for chunk := (n + 1) / 2; chunk >= 1; chunk /= 2 {
+		for start := 0; start < n; {
+			end := start + chunk
+			if end > n {
+				end = n
+			}
+			restore := remove(start, end)
+			if m.reproduces() {
+				n -= end - start
+				removed = true
+				continue
+			}
+			restore()
+			start = end
+		}
+	}
+	return removed
+}
+
+// truncate removes all actions after time t.
+func (m *minimizer) truncate(t *Int) {
+	old := m.plan
+	m.plan = make([]*section, 0, len(old))
+	for _, s := range old {
+		truncated := &section{header: s.header}
+		for _, l := range s.lines {
+			var time Int
+			if _, ok := time.SetString(strings.SplitN(l, " ", 2)[0], 10); ok && time.Cmp(t) != 1 {
+				truncated.lines = append(truncated.lines, l)
+			}
+		}
+		m.plan = append(m.plan, truncated)
+	}
+	if !m.reproduces() {
+		m.plan = old
+		return
+	}
+	m.progress("actions after "+t.String(), true)
+}
+
+// reduceEntities removes trains or passengers defined in the input section header together with their plans,
+// which have a header starting with prefix.
+func (m *minimizer) reduceEntities(header, prefix string) bool {
+	var entities *section
+	for _, s := range m.input {
+		if s.header == header {
+			entities = s
+		}
+	}
+	if entities == nil {
+		return false
+	}
+	removed := m.ddmin(len(entities.lines), func(start, end int) func() {
+		oldLines, oldPlan := entities.lines, m.plan
+		drop := make(map[string]bool, end-start)
+		for _, l := range entities.lines[start:end] {
+			drop[prefix+strings.SplitN(l, " ", 2)[0]+"]"] = true
+		}
+		entities.lines = append(append([]string{}, entities.lines[:start]...), entities.lines[end:]...)
+		m.plan = make([]*section, 0, len(oldPlan))
+		for _, s := range oldPlan {
+			if !drop[s.header] {
+				m.plan = append(m.plan, s)
+			}
+		}
+		return func() { entities.lines, m.plan = oldLines, oldPlan }
+	})
+	m.progress(header, removed)
+	return removed
+}
+
+// reduceLines removes lines of s. If unused is set, only lines defining an ID not referenced by any other section are removed.
+func (m *minimizer) reduceLines(s *section, unused bool) bool {
+	var candidates []int
+	var referenced map[string]bool
+	if unused {
+		referenced = m.references(s)
+	}
+	for i, l := range s.lines {
+		if !referenced[strings.SplitN(l, " ", 2)[0]] {
+			candidates = append(candidates, i)
+		}
+	}
+
+	removed := m.ddmin(len(candidates), func(start, end int) func() {
+		old, oldCandidates := s.lines, candidates
+		drop := make(map[int]bool, end-start)
+		for _, i := range candidates[start:end] {
+			drop[i] = true
+		}
+		s.lines = make([]string, 0, len(old))
+		for i := range old {
+			if !drop[i] {
+				s.lines = append(s.lines, old[i])
+			}
+		}
+		candidates = append(append([]int{}, candidates[:start]...), candidates[end:]...)
+		for i := start; i < len(candidates); i++ {
+			candidates[i] -= end - start
+		}
+		return func() { s.lines, candidates = old, oldCandidates }
+	})
+	m.progress(s.header, removed)
+	return removed
+}
+
+// reduceNetwork removes stations not used by trains and passengers together with all lines connected to them.
+// Stations at the end of a line used by a train are kept.
+func (m *minimizer) reduceNetwork() bool {
+	var stations, lines *section
+	for _, s := range m.input {
+		switch s.header {
+		case "[Stations]":
+			stations = s
+		case "[Lines]":
+			lines = s
+		}
+	}
+	if stations == nil || lines == nil {
+		return false
+	}
+
+	referenced := m.references(stations, lines)
+	for _, l := range lines.lines {
+		f := strings.Fields(l)
+		if len(f) >= 3 && referenced[f[0]] {
+			referenced[f[1]], referenced[f[2]] = true, true
+		}
+	}
+	var candidates []string
+	for _, l := range stations.lines {
+		if id := strings.SplitN(l, " ", 2)[0]; !referenced[id] {
+			candidates = append(candidates, id)
+		}
+	}
+
+	removed := m.ddmin(len(candidates), func(start, end int) func() {
+		oldStations, oldLines, oldCandidates := stations.lines, lines.lines, candidates
+		drop := make(map[string]bool, end-start)
+		for _, id := range candidates[start:end] {
+			drop[id] = true
+		}
+		stations.lines = make([]string, 0, len(oldStations))
+		for _, l := range oldStations {
+			if !drop[strings.SplitN(l, " ", 2)[0]] {
+				stations.lines = append(stations.lines, l)
+			}
+		}
+		lines.lines = make([]string, 0, len(oldLines))
+		for _, l := range oldLines {
+			if f := strings.Fields(l); len(f) < 3 || (!drop[f[1]] && !drop[f[2]]) {
+				lines.lines = append(lines.lines, l)
+			}
+		}
+		candidates = append(append([]string{}, candidates[:start]...), candidates[end:]...)
+		return func() { stations.lines, lines.lines, candidates = oldStations, oldLines, oldCandidates }
+	})
+	m.progress("[Stations] and [Lines]", removed)
+	return removed
+}
+
+// references returns all words used in input and plan outside of the sections skip.
+func (m *minimizer) references(skip ...*section) map[string]bool {
+	referenced := make(map[string]bool)
+	for _, other := range append(append([]*section{}, m.input...), m.plan...) {
+		if containsSection(skip, other) {
+			continue
+		}
+		for _, l := range other.lines {
+			for _, word := range strings.Fields(l) {
+				referenced[word] = true
+			}
+		}
+	}
+	return referenced
+}
+
+func containsSection(sections []*section, s *section) bool {
+	for _, other := range sections {
+		if other == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *minimizer) progress(what string, removed bool) {
+	if m.verbose == nil || !removed {
+		return
+	}
+	lines := 0
+	for _, s := range append(append([]*section{}, m.input...), m.plan...) {
+		lines += len(s.lines)
+	}
+	fmt.Fprintf(m.verbose, "%s reduced, %d lines left after %d runs\n", what, lines, m.runs)
+}
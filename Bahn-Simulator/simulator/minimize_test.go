@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestMinimize(t *testing.T) {
+	input, err := os.ReadFile(path.Join("..", "test", "boardingOnLine", "input.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := os.ReadFile(path.Join("..", "test", "boardingOnLine", "output.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	minInput, minPlan, class, err := Minimize(input, plan, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if class != "passenger: passenger (_): boarding not possible at _" {
+		t.Errorf("wrong class %q", class)
+	}
+	if len(minInput) >= len(input) || len(minPlan) >= len(plan) {
+		t.Errorf("nothing removed:\n%s\n%s", minInput, minPlan)
+	}
+
+	w, err := ParseInputReader(bytes.NewReader(minInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ParsePlanReader(w, bytes.NewReader(minPlan))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := w.Run(Options{})
+	if r.Valid || ErrorClass(w, r.Errors[0]) != class {
+		t.Errorf("reproducer fails differently: %v", r.Errors)
+	}
+	if len(w.Trains) != 1 || len(w.Passengers) != 1 {
+		t.Errorf("unused entities not removed:\n%s", minInput)
+	}
+}
+
+func TestMinimizeNetwork(t *testing.T) {
+	input, err := os.ReadFile(path.Join("..", "test", "boardingOnLine", "input.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := os.ReadFile(path.Join("..", "test", "boardingOnLine", "output.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Add a branch not used by any train or passenger
+	input = bytes.Replace(input, []byte("S3 2\n"), []byte("S3 2\nS4 1\nS5 1\n"), 1)
+	input = bytes.Replace(input, []byte("L2 S2 S1 4 1\n"), []byte("L2 S2 S1 4 1\nL3 S3 S4 1 1\nL4 S4 S5 1 1\n"), 1)
+
+	minInput, minPlan, _, err := Minimize(input, plan, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := ParseInputReader(bytes.NewReader(minInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ParsePlanReader(w, bytes.NewReader(minPlan))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"S4", "S5"} {
+		if w.Stations[id] != nil {
+			t.Errorf("unused station %s not removed:\n%s", id, minInput)
+		}
+	}
+	for _, id := range []string{"L3", "L4"} {
+		if w.Lines[id] != nil {
+			t.Errorf("unused line %s not removed:\n%s", id, minInput)
+		}
+	}
+	if !w.CheckConnected() {
+		t.Errorf("network not connected:\n%s", minInput)
+	}
+}
+
+func TestMinimizeValidPlan(t *testing.T) {
+	input, err := os.ReadFile(path.Join("..", "test", "simple", "input.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := os.ReadFile(path.Join("..", "test", "simple", "output.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, _, err = Minimize(input, plan, nil)
+	if err == nil {
+		t.Error("valid plan minimized")
+	}
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+// Edge is a line incident to a station together with the station at the other end.
+type Edge struct {
+	Line    *Line
+	Station string
+
+	// node of Station in the network
+	node int
+}
+
+// Network is the graph of stations and lines of a world.
+// Stations are nodes, lines are undirected edges. Lines without exactly two ends are not part of the network.
+// Stations referenced by lines but missing in the world are included as nodes so that the network mirrors the input.
+//
+// A Network is not updated automatically, see World.UpdateNetwork.
+type Network struct {
+	ids       []string
+	index     map[string]int
+	known     int
+	adjacency [][]Edge
+	between   map[[2]int][]*Line
+}
+
+// NewNetwork builds the network of w. Stations and lines are ordered by their natural ID order.
+func NewNetwork(w *World) *Network {
+	n := &Network{
+		ids:     make([]string, 0, len(w.Stations)),
+		index:   make(map[string]int, len(w.Stations)),
+		between: make(map[[2]int][]*Line, len(w.Lines)),
+	}
+	for k := range w.Stations {
+		n.ids = append(n.ids, k)
+	}
+	SortIDs(n.ids)
+	for i := range n.ids {
+		n.index[n.ids[i]] = i
+	}
+	n.known = len(n.ids)
+
+	lines := make([]string, 0, len(w.Lines))
+	for k := range w.Lines {
+		if len(w.Lines[k].End) == 2 {
+			lines = append(lines, k)
+		}
+	}
+	SortIDs(lines)
+
+	n.adjacency = make([][]Edge, len(n.ids))
+	for _, k := range lines {
+		l := w.Lines[k]
+		a, b := n.node(l.End[0]), n.node(l.End[1])
+		n.adjacency[a] = append(n.adjacency[a], Edge{Line: l, Station: l.End[1], node: b})
+		if a != b {
+			n.adjacency[b] = append(n.adjacency[b], Edge{Line: l, Station: l.End[0], node: a})
+		}
+		key := pairKey(a, b)
+		n.between[key] = append(n.between[key], l)
+	}
+	return n
+}
+
+// node returns the node of a station, adding the station if it is unknown.
+func (n *Network) node(station string) int {
+	i, ok := n.index[station]
+	if !ok {
+		i = len(n.ids)
+		n.ids = append(n.ids, station)
+		n.index[station] = i
+		n.adjacency = append(n.adjacency, nil)
+	}
+	return i
+}
+
+func pairKey(a, b int) [2]int {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]int{a, b}
+}
+
+// Edges returns all lines incident to station. The result must not be modified.
+func (n *Network) Edges(station string) []Edge {
+	i, ok := n.index[station]
+	if !ok {
+		return nil
+	}
+	return n.adjacency[i]
+}
+
+// LinesBetween returns all lines connecting the stations a and b. The result must not be modified.
+func (n *Network) LinesBetween(a, b string) []*Line {
+	i, ok := n.index[a]
+	if !ok {
+		return nil
+	}
+	j, ok := n.index[b]
+	if !ok {
+		return nil
+	}
+	return n.between[pairKey(i, j)]
+}
+
+// Connected returns whether all stations of the world are connected. It runs in linear time.
+func (n *Network) Connected() bool {
+	if n.known == 0 {
+		return true
+	}
+	marked := make([]bool, len(n.ids))
+	stack := make([]int, 1, len(n.ids))
+	marked[0] = true
+	reached := 1
+	for len(stack) != 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, e := range n.adjacency[current] {
+			next := e.node
+			if marked[next] {
+				continue
+			}
+			marked[next] = true
+			if next < n.known {
+				reached++
+			}
+			stack = append(stack, next)
+		}
+	}
+	return reached == n.known
+}
+
+// Stations returns the IDs of all stations of the world in natural order.
+func (n *Network) Stations() []string {
+	return append([]string(nil), n.ids[:n.known]...)
+}
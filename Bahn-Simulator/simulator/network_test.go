@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNetwork(t *testing.T) {
+	input := "[Stations]\nS1 1\nS2 1\nS3 1\nS10 1\n[Lines]\nL10 S2 S1 1 1\nL2 S1 S2 1 1\nL3 S3 S2 1 1\n"
+	w, err := ParseInputReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := w.Network()
+
+	edges := n.Edges("S2")
+	if len(edges) != 3 || edges[0].Line.ID != "L2" || edges[0].Station != "S1" || edges[1].Line.ID != "L3" || edges[1].Station != "S3" || edges[2].Line.ID != "L10" {
+		t.Errorf("wrong edges of S2: %v", edges)
+	}
+	if len(n.Edges("S10")) != 0 || n.Edges("S99") != nil {
+		t.Error("S10 and S99 must not have edges")
+	}
+
+	if other, ok := w.Lines["L3"].OtherEnd("S2"); !ok || other != "S3" {
+		t.Errorf("wrong other end of L3: %s %v", other, ok)
+	}
+	if _, ok := w.Lines["L3"].OtherEnd("S1"); ok {
+		t.Error("L3 does not connect to S1")
+	}
+
+	between := n.LinesBetween("S2", "S1")
+	if len(between) != 2 || between[0].ID != "L2" || between[1].ID != "L10" {
+		t.Errorf("wrong lines between S1 and S2: %v", between)
+	}
+	if len(n.LinesBetween("S1", "S3")) != 0 {
+		t.Error("no line between S1 and S3")
+	}
+
+	if w.CheckConnected() {
+		t.Error("S10 is not connected")
+	}
+	w.Lines["L4"] = &Line{ID: "L4", End: []string{"S10", "S3"}}
+	w.UpdateNetwork()
+	if !w.CheckConnected() {
+		t.Error("all stations are connected")
+	}
+}
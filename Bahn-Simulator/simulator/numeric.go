@@ -0,0 +1,481 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Int is an arbitrary precision integer.
+// Values fitting into an int64 are stored as machine integers, larger values fall back to big.Int.
+// The zero value is 0. In contrast to big.Int, copying an Int is safe: arbitrary precision values are never modified in place.
+// The methods mirror the ones of big.Int.
+type Int struct {
+	small int64
+	// big is only set if the value does not fit into an int64
+	big *big.Int
+}
+
+// NewInt returns a new Int set to x.
+func NewInt(x int64) *Int {
+	return &Int{small: x}
+}
+
+// setBig sets z to x, which must not be modified afterwards.
+func (z *Int) setBig(x *big.Int) *Int {
+	if x.IsInt64() {
+		z.small, z.big = x.Int64(), nil
+		return z
+	}
+	z.small, z.big = 0, x
+	return z
+}
+
+// toBig returns x as big.Int. The result must not be modified.
+func (x *Int) toBig() *big.Int {
+	if x.big != nil {
+		return x.big
+	}
+	return big.NewInt(x.small)
+}
+
+// Big returns a copy of x as big.Int.
+func (x *Int) Big() *big.Int {
+	return new(big.Int).Set(x.toBig())
+}
+
+// SetBig sets z to x.
+func (z *Int) SetBig(x *big.Int) *Int {
+	return z.setBig(new(big.Int).Set(x))
+}
+
+// IsInt64 reports whether x can be represented as an int64.
+func (x *Int) IsInt64() bool {
+	return x.big == nil
+}
+
+// Int64 returns the int64 representation of x. The result is undefined if x does not fit into an int64.
+func (x *Int) Int64() int64 {
+	return x.small
+}
+
+func (z *Int) Set(x *Int) *Int {
+	z.small, z.big = x.small, x.big
+	return z
+}
+
+func (z *Int) SetInt64(x int64) *Int {
+	z.small, z.big = x, nil
+	return z
+}
+
+func (z *Int) SetString(s string, base int) (*Int, bool) {
+	v, err := strconv.ParseInt(s, base, 64)
+	if err == nil {
+		z.small, z.big = v, nil
+		return z, true
+	}
+	b, ok := new(big.Int).SetString(s, base)
+	if !ok {
+		return nil, false
+	}
+	return z.setBig(b), true
+}
+
+func (z *Int) Add(x, y *Int) *Int {
+	if x.big == nil && y.big == nil {
+		s := x.small + y.small
+		if (x.small^s)&(y.small^s) >= 0 {
+			z.small, z.big = s, nil
+			return z
+		}
+	}
+	return z.setBig(new(big.Int).Add(x.toBig(), y.toBig()))
+}
+
+func (z *Int) Sub(x, y *Int) *Int {
+	if x.big == nil && y.big == nil {
+		d := x.small - y.small
+		if (x.small^y.small)&(x.small^d) >= 0 {
+			z.small, z.big = d, nil
+			return z
+		}
+	}
+	return z.setBig(new(big.Int).Sub(x.toBig(), y.toBig()))
+}
+
+func (z *Int) Mul(x, y *Int) *Int {
+	if x.big == nil && y.big == nil {
+		if p, ok := mul64(x.small, y.small); ok {
+			z.small, z.big = p, nil
+			return z
+		}
+	}
+	return z.setBig(new(big.Int).Mul(x.toBig(), y.toBig()))
+}
+
+func (x *Int) Cmp(y *Int) int {
+	if x.big == nil && y.big == nil {
+		switch {
+		case x.small < y.small:
+			return -1
+		case x.small > y.small:
+			return +1
+		}
+		return 0
+	}
+	return x.toBig().Cmp(y.toBig())
+}
+
+func (x *Int) Sign() int {
+	if x.big != nil {
+		return x.big.Sign()
+	}
+	switch {
+	case x.small < 0:
+		return -1
+	case x.small > 0:
+		return +1
+	}
+	return 0
+}
+
+func (x *Int) String() string {
+	if x.big != nil {
+		return x.big.String()
+	}
+	return strconv.FormatInt(x.small, 10)
+}
+
+// MarshalJSON encodes x as JSON number.
+func (x *Int) MarshalJSON() ([]byte, error) {
+	return []byte(x.String()), nil
+}
+
+// UnmarshalJSON decodes a JSON number into z.
+func (z *Int) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	_, ok := z.SetString(string(b), 10)
+	if !ok {
+		return &strconv.NumError{Func: "UnmarshalJSON", Num: string(b), Err: strconv.ErrSyntax}
+	}
+	return nil
+}
+
+// mul64 returns x*y and whether the result did not overflow.
+func mul64(x, y int64) (int64, bool) {
+	if x == 0 || y == 0 {
+		return 0, true
+	}
+	p := x * y
+	if p/y != x || (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+		return 0, false
+	}
+	return p, true
+}
+
+// pow10 contains all powers of 10 fitting into an int64.
+var pow10 = [...]int64{1, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9, 1e10, 1e11, 1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18}
+
+// Rat is an exact rational number.
+// Decimal values (num / 10^scale) fitting into an int64 are stored as machine integers,
+// all other values fall back to big.Rat.
+// The zero value is 0. In contrast to big.Rat, copying a Rat is safe: arbitrary precision values are never modified in place.
+// The methods mirror the ones of big.Rat.
+type Rat struct {
+	num   int64
+	scale int
+	// big is only set if the value can not be represented as num / 10^scale
+	big *big.Rat
+}
+
+// NewRat returns a new Rat set to a/b. b must not be 0.
+func NewRat(a, b int64) *Rat {
+	if b == 1 {
+		return &Rat{num: a}
+	}
+	return new(Rat).setBig(big.NewRat(a, b))
+}
+
+// setBig sets z to x, which must not be modified afterwards.
+func (z *Rat) setBig(x *big.Rat) *Rat {
+	// Try to find a representation as decimal
+	den := x.Denom()
+	if x.Num().IsInt64() && den.IsInt64() {
+		for scale := range pow10 {
+			if pow10[scale] < den.Int64() || pow10[scale]%den.Int64() != 0 {
+				continue
+			}
+			if n, ok := mul64(x.Num().Int64(), pow10[scale]/den.Int64()); ok {
+				z.num, z.scale, z.big = n, scale, nil
+				z.normalize()
+				return z
+			}
+			break
+		}
+	}
+	z.num, z.scale, z.big = 0, 0, x
+	return z
+}
+
+// normalize removes trailing zeros of fixed point values.
+func (z *Rat) normalize() {
+	if z.num == 0 {
+		z.scale = 0
+		return
+	}
+	for z.scale > 0 && z.num%10 == 0 {
+		z.num /= 10
+		z.scale--
+	}
+}
+
+// toBig returns x as big.Rat. The result must not be modified.
+func (x *Rat) toBig() *big.Rat {
+	if x.big != nil {
+		return x.big
+	}
+	return new(big.Rat).SetFrac(big.NewInt(x.num), big.NewInt(pow10[x.scale]))
+}
+
+// Big returns a copy of x as big.Rat.
+func (x *Rat) Big() *big.Rat {
+	return new(big.Rat).Set(x.toBig())
+}
+
+// align returns the numerators of x and y scaled to a common denominator 10^scale.
+// It returns false if one of the values is not a fixed point value or the result overflows.
+func align(x, y *Rat) (int64, int64, int, bool) {
+	if x.big != nil || y.big != nil {
+		return 0, 0, 0, false
+	}
+	a, b, scale := x.num, y.num, x.scale
+	var ok bool
+	switch {
+	case x.scale < y.scale:
+		a, ok = mul64(a, pow10[y.scale-x.scale])
+		scale = y.scale
+	case x.scale > y.scale:
+		b, ok = mul64(b, pow10[x.scale-y.scale])
+	default:
+		ok = true
+	}
+	return a, b, scale, ok
+}
+
+func (z *Rat) Set(x *Rat) *Rat {
+	z.num, z.scale, z.big = x.num, x.scale, x.big
+	return z
+}
+
+func (z *Rat) SetInt(x *Int) *Rat {
+	if x.big != nil {
+		return z.setBig(new(big.Rat).SetInt(x.big))
+	}
+	z.num, z.scale, z.big = x.small, 0, nil
+	return z
+}
+
+func (z *Rat) SetInt64(x int64) *Rat {
+	z.num, z.scale, z.big = x, 0, nil
+	return z
+}
+
+// SetString sets z to the value of s, which can be a decimal number ("3.14") or a fraction ("157/50").
+func (z *Rat) SetString(s string) (*Rat, bool) {
+	if i := strings.IndexByte(s, '.'); i >= 0 && !strings.ContainsAny(s, "/eE") {
+		scale := len(s) - i - 1
+		if scale < len(pow10) {
+			n, err := strconv.ParseInt(s[:i]+s[i+1:], 10, 64)
+			if err == nil && (i > 0 || scale > 0) {
+				z.num, z.scale, z.big = n, scale, nil
+				z.normalize()
+				return z, true
+			}
+		}
+	} else if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		z.num, z.scale, z.big = n, 0, nil
+		return z, true
+	}
+	b, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, false
+	}
+	return z.setBig(b), true
+}
+
+func (z *Rat) Add(x, y *Rat) *Rat {
+	if a, b, scale, ok := align(x, y); ok {
+		s := a + b
+		if (a^s)&(b^s) >= 0 {
+			z.num, z.scale, z.big = s, scale, nil
+			z.normalize()
+			return z
+		}
+	}
+	return z.setBig(new(big.Rat).Add(x.toBig(), y.toBig()))
+}
+
+func (z *Rat) Sub(x, y *Rat) *Rat {
+	if a, b, scale, ok := align(x, y); ok {
+		d := a - b
+		if (a^b)&(a^d) >= 0 {
+			z.num, z.scale, z.big = d, scale, nil
+			z.normalize()
+			return z
+		}
+	}
+	return z.setBig(new(big.Rat).Sub(x.toBig(), y.toBig()))
+}
+
+func (z *Rat) Mul(x, y *Rat) *Rat {
+	if x.big == nil && y.big == nil && x.scale+y.scale < len(pow10) {
+		if p, ok := mul64(x.num, y.num); ok {
+			z.num, z.scale, z.big = p, x.scale+y.scale, nil
+			z.normalize()
+			return z
+		}
+	}
+	return z.setBig(new(big.Rat).Mul(x.toBig(), y.toBig()))
+}
+
+func (x *Rat) Cmp(y *Rat) int {
+	if a, b, _, ok := align(x, y); ok {
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return +1
+		}
+		return 0
+	}
+	return x.toBig().Cmp(y.toBig())
+}
+
+func (x *Rat) Sign() int {
+	if x.big != nil {
+		return x.big.Sign()
+	}
+	switch {
+	case x.num < 0:
+		return -1
+	case x.num > 0:
+		return +1
+	}
+	return 0
+}
+
+// CeilQuo returns the smallest integer not less than x/y. y must not be 0.
+func (x *Rat) CeilQuo(y *Rat) *Int {
+	if a, b, _, ok := align(x, y); ok && b != math.MinInt64 && a != math.MinInt64 {
+		if b < 0 {
+			a, b = -a, -b
+		}
+		q := a / b
+		if a%b != 0 && a > 0 {
+			q++
+		}
+		return NewInt(q)
+	}
+	q := new(big.Rat).Quo(x.toBig(), y.toBig())
+	// big.Int.Div rounds towards negative infinity for positive divisors, so ceil(a/b) = -floor(-a/b)
+	n := new(big.Int).Neg(q.Num())
+	n.Div(n, q.Denom())
+	return new(Int).setBig(n.Neg(n))
+}
+
+// String returns x as exact decimal number if possible ("3.14") and as fraction otherwise ("1/3").
+func (x *Rat) String() string {
+	if x.big != nil {
+		if digits, ok := decimalDigits(x.big.Denom()); ok {
+			return x.big.FloatString(digits)
+		}
+		return x.big.RatString()
+	}
+	if x.scale == 0 {
+		return strconv.FormatInt(x.num, 10)
+	}
+	s := strconv.FormatInt(x.num, 10)
+	sign := ""
+	if x.num < 0 {
+		sign, s = "-", s[1:]
+	}
+	if len(s) <= x.scale {
+		s = strings.Repeat("0", x.scale-len(s)+1) + s
+	}
+	return sign + s[:len(s)-x.scale] + "." + s[len(s)-x.scale:]
+}
+
+// MarshalText encodes x as returned by String.
+func (x *Rat) MarshalText() ([]byte, error) {
+	return []byte(x.String()), nil
+}
+
+// UnmarshalText decodes a value encoded by MarshalText.
+func (z *Rat) UnmarshalText(b []byte) error {
+	_, ok := z.SetString(string(b))
+	if !ok {
+		return &strconv.NumError{Func: "UnmarshalText", Num: string(b), Err: strconv.ErrSyntax}
+	}
+	return nil
+}
+
+// decimalDigits returns the number of decimal digits needed to represent a fraction with denominator den exactly.
+// It returns false if den has prime factors other than 2 and 5.
+func decimalDigits(den *big.Int) (int, bool) {
+	d := new(big.Int).Set(den)
+	twos := int(d.TrailingZeroBits())
+	d.Rsh(d, uint(twos))
+	fives := 0
+	five := big.NewInt(5)
+	m := new(big.Int)
+	for {
+		q, r := new(big.Int).QuoRem(d, five, m)
+		if r.Sign() != 0 {
+			break
+		}
+		d = q
+		fives++
+	}
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return 0, false
+	}
+	if twos > fives {
+		return twos, true
+	}
+	return fives, true
+}
+
+// clone returns a copy of x which does not share memory with x.
+func (x *Int) clone() Int {
+	if x.big == nil {
+		return *x
+	}
+	return Int{big: new(big.Int).Set(x.big)}
+}
+
+// clone returns a copy of x which does not share memory with x.
+func (x *Rat) clone() Rat {
+	if x.big == nil {
+		return *x
+	}
+	return Rat{big: new(big.Rat).Set(x.big)}
+}
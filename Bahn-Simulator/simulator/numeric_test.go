@@ -0,0 +1,201 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"math"
+	"math/big"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestIntOverflow(t *testing.T) {
+	max := NewInt(math.MaxInt64)
+	min := NewInt(math.MinInt64)
+
+	sum := new(Int).Add(max, NewInt(1))
+	if sum.IsInt64() || sum.String() != "9223372036854775808" {
+		t.Errorf("MaxInt64+1: got %s (small: %v)", sum, sum.IsInt64())
+	}
+	back := new(Int).Sub(sum, NewInt(1))
+	if !back.IsInt64() || back.Cmp(max) != 0 {
+		t.Errorf("MaxInt64+1-1: got %s (small: %v)", back, back.IsInt64())
+	}
+	diff := new(Int).Sub(min, NewInt(1))
+	if diff.String() != "-9223372036854775809" || diff.Cmp(min) != -1 || diff.Sign() != -1 {
+		t.Errorf("MinInt64-1: got %s", diff)
+	}
+	prod := new(Int).Mul(min, NewInt(-1))
+	if prod.String() != "9223372036854775808" || prod.Cmp(max) != +1 {
+		t.Errorf("MinInt64*-1: got %s", prod)
+	}
+
+	// Copies must not share state
+	copied := *sum
+	sum.Add(sum, sum)
+	if copied.String() != "9223372036854775808" {
+		t.Errorf("copy changed: got %s", copied.String())
+	}
+
+	huge, ok := new(Int).SetString("123456789012345678901234567890", 10)
+	if !ok || huge.String() != "123456789012345678901234567890" {
+		t.Errorf("SetString: got %s, %v", huge, ok)
+	}
+}
+
+func TestRat(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"3.14", "3.14"},
+		{"5", "5"},
+		{"5.", "5"},
+		{"2.50", "2.5"},
+		{"0.001", "0.001"},
+		{"157/50", "3.14"},
+		{"1/3", "1/3"},
+		{"0.1234567890123456789012", "0.1234567890123456789012"},
+		{"92233720368547758070.5", "92233720368547758070.5"},
+	}
+	for _, tc := range tests {
+		r, ok := new(Rat).SetString(tc.in)
+		if !ok {
+			t.Errorf("%s: can not parse", tc.in)
+			continue
+		}
+		if r.String() != tc.out {
+			t.Errorf("%s: got %s, want %s", tc.in, r.String(), tc.out)
+		}
+		want, _ := new(big.Rat).SetString(tc.in)
+		if r.Big().Cmp(want) != 0 {
+			t.Errorf("%s: got value %s, want %s", tc.in, r.Big(), want)
+		}
+	}
+
+	// Mixed fixed point and fallback arithmetic must stay exact
+	third := NewRat(1, 3)
+	x, _ := new(Rat).SetString("0.5")
+	sum := new(Rat).Add(third, x)
+	if sum.String() != "5/6" {
+		t.Errorf("1/3+0.5: got %s", sum)
+	}
+	back := new(Rat).Sub(sum, third)
+	if back.String() != "0.5" || back.big != nil {
+		t.Errorf("5/6-1/3: got %s (fallback: %v)", back, back.big != nil)
+	}
+}
+
+func TestRatCeilQuo(t *testing.T) {
+	tests := []struct {
+		x, y, want string
+	}{
+		{"10", "3", "4"},
+		{"9", "3", "3"},
+		{"-10", "3", "-3"},
+		{"0", "3", "0"},
+		{"1", "0.3", "4"},
+		{"0.9", "0.3", "3"},
+		{"1", "1/3", "3"},
+		{"2", "1/3", "6"},
+		{"1/2", "1/3", "2"},
+		{"-1/2", "1/3", "-1"},
+	}
+	for _, tc := range tests {
+		x, _ := new(Rat).SetString(tc.x)
+		y, _ := new(Rat).SetString(tc.y)
+		if got := x.CeilQuo(y).String(); got != tc.want {
+			t.Errorf("ceil(%s/%s): got %s, want %s", tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
+func BenchmarkIntAdd(b *testing.B) {
+	x := NewInt(0)
+	one := NewInt(1)
+	for i := 0; i < b.N; i++ {
+		x.Add(x, one)
+	}
+}
+
+func BenchmarkBigIntAdd(b *testing.B) {
+	x := big.NewInt(0)
+	one := big.NewInt(1)
+	for i := 0; i < b.N; i++ {
+		x.Add(x, one)
+	}
+}
+
+func BenchmarkRatMulCmp(b *testing.B) {
+	since := new(Rat)
+	speed, _ := new(Rat).SetString("0.75")
+	length, _ := new(Rat).SetString("1000.5")
+	one := NewRat(1, 1)
+	d := new(Rat)
+	for i := 0; i < b.N; i++ {
+		since.Add(since, one)
+		d.Mul(since, speed)
+		if d.Cmp(length) != -1 {
+			since.SetInt64(0)
+		}
+	}
+}
+
+func BenchmarkBigRatMulCmp(b *testing.B) {
+	since := new(big.Rat)
+	speed, _ := new(big.Rat).SetString("0.75")
+	length, _ := new(big.Rat).SetString("1000.5")
+	one := big.NewRat(1, 1)
+	d := new(big.Rat)
+	for i := 0; i < b.N; i++ {
+		since.Add(since, one)
+		d.Mul(since, speed)
+		if d.Cmp(length) != -1 {
+			since.SetInt64(0)
+		}
+	}
+}
+
+// BenchmarkSimulateLarge parses and simulates test/large, skipping idle timesteps and tick by tick.
+func BenchmarkSimulateLarge(b *testing.B) {
+	input := path.Join("..", "test", "large", "input.txt")
+	plan := path.Join("..", "test", "large", "output.txt")
+	if _, err := os.Stat(input); err != nil {
+		b.Skip("test/large not available:", err)
+	}
+	for _, tickByTick := range []bool{false, true} {
+		name := "scheduled"
+		if tickByTick {
+			name = "tick-by-tick"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				w, err := ParseInput(input)
+				if err != nil {
+					b.Fatal(err)
+				}
+				err = ParsePlan(w, plan)
+				if err != nil {
+					b.Fatal(err)
+				}
+				r := w.Run(Options{TickByTick: tickByTick})
+				if !r.Valid {
+					b.Fatal("invalid result:", r.Errors)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type ParseErrorKind int
+
+const (
+	ParseErrorInternal ParseErrorKind = iota
+	ParseErrorSyntax
+	ParseErrorMissingSection
+	ParseErrorUnknownSection
+	ParseErrorInvalidValue
+	ParseErrorDuplicate
+	ParseErrorUnknownReference
+)
+
+func (k ParseErrorKind) String() string {
+	switch k {
+	case ParseErrorSyntax:
+		return "syntax"
+	case ParseErrorMissingSection:
+		return "missing-section"
+	case ParseErrorUnknownSection:
+		return "unknown-section"
+	case ParseErrorInvalidValue:
+		return "invalid-value"
+	case ParseErrorDuplicate:
+		return "duplicate"
+	case ParseErrorUnknownReference:
+		return "unknown-reference"
+	default:
+		return "internal"
+	}
+}
+
+// ParseError describes a single problem found while parsing an input or plan.
+// Line and Column start at 1. Column and Field are only set if the problem can be attributed to a single field.
+type ParseError struct {
+	File    string
+	Line    int
+	Column  int
+	Field   string
+	Section string
+	Kind    ParseErrorKind
+	Text    string
+	Message string
+}
+
+// Error formats the error as "file:line:column: section message (kind): 'text'" so that editors can jump to it.
+func (e *ParseError) Error() string {
+	var b strings.Builder
+	if e.File != "" {
+		b.WriteString(e.File)
+		b.WriteString(":")
+	}
+	fmt.Fprintf(&b, "%d:", e.Line)
+	if e.Column > 0 {
+		fmt.Fprintf(&b, "%d:", e.Column)
+	}
+	b.WriteString(" ")
+	if e.Section != "" {
+		fmt.Fprintf(&b, "%s ", e.Section)
+	}
+	fmt.Fprintf(&b, "%s (%s)", e.Message, e.Kind.String())
+	if e.Field != "" {
+		fmt.Fprintf(&b, " in field %s", e.Field)
+	}
+	if e.Text != "" {
+		fmt.Fprintf(&b, ": '%s'", e.Text)
+	}
+	return b.String()
+}
+
+// ParseErrors contains all errors found while parsing a single document in the order of their occurrence.
+type ParseErrors []*ParseError
+
+// Error returns all errors, one per line.
+func (e ParseErrors) Error() string {
+	s := make([]string, len(e))
+	for i := range e {
+		s[i] = e[i].Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+// Unwrap returns the contained errors.
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i := range e {
+		errs[i] = e[i]
+	}
+	return errs
+}
+
+func (e ParseErrors) setFile(file string) {
+	for i := range e {
+		e[i].File = file
+	}
+}
+
+// errorOrNil returns nil if no errors were found.
+// This avoids returning a non-nil error interface holding an empty slice.
+func (e ParseErrors) errorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// parseState tracks the position of a parser for error reporting.
+type parseState struct {
+	line    int
+	section string
+	text    string
+	matches []int
+	re      *regexp.Regexp
+}
+
+// match matches the current text against re and remembers the match for later calls to value and errorField.
+// It returns false if the text does not match.
+func (p *parseState) match(re *regexp.Regexp) bool {
+	p.re = re
+	p.matches = re.FindStringSubmatchIndex(p.text)
+	return p.matches != nil
+}
+
+// value returns the i-th subexpression of the last match.
+func (p *parseState) value(i int) string {
+	if p.matches == nil || p.matches[2*i] < 0 {
+		return ""
+	}
+	return p.text[p.matches[2*i]:p.matches[2*i+1]]
+}
+
+// errorf returns a ParseError for the current line.
+func (p *parseState) errorf(kind ParseErrorKind, format string, a ...interface{}) *ParseError {
+	return &ParseError{
+		Line:    p.line,
+		Section: p.section,
+		Kind:    kind,
+		Text:    p.text,
+		Message: fmt.Sprintf(format, a...),
+	}
+}
+
+// errorField returns a ParseError for the i-th subexpression of the last match.
+func (p *parseState) errorField(kind ParseErrorKind, i int, format string, a ...interface{}) *ParseError {
+	err := p.errorf(kind, format, a...)
+	if p.re != nil && p.matches != nil && p.matches[2*i] >= 0 {
+		err.Column = p.matches[2*i] + 1
+		err.Field = p.re.SubexpNames()[i]
+	}
+	return err
+}
@@ -0,0 +1,329 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type InputMode int
+
+const (
+	InputUnknown = iota
+	InputLines
+	InputStations
+	InputTrains
+	InputPassengers
+)
+
+var (
+	inputStationsRegexp               = regexp.MustCompile(`\A(?P<id>[a-zA-Z0-9_]+) (?P<kapazitaet>[\d]+)[\s]*\z`)
+	inputStationsRegexpID             = inputStationsRegexp.SubexpIndex("id")
+	inputStationsRegexpKapazität      = inputStationsRegexp.SubexpIndex("kapazitaet")
+	inputLinesRegexp                  = regexp.MustCompile(`\A(?P<id>[a-zA-Z0-9_]+) (?P<anfang>[a-zA-Z0-9_]+) (?P<ende>[a-zA-Z0-9_]+) (?P<laenge>[\d]+\.?[\d]*) (?P<kapazitaet>[\d]+)[\s]*\z`)
+	inputLinesRegexpID                = inputLinesRegexp.SubexpIndex("id")
+	inputLinesRegexpAnfang            = inputLinesRegexp.SubexpIndex("anfang")
+	inputLinesRegexpEnde              = inputLinesRegexp.SubexpIndex("ende")
+	inputLinesRegexpLänge             = inputLinesRegexp.SubexpIndex("laenge")
+	inputLinesRegexpKapazität         = inputLinesRegexp.SubexpIndex("kapazitaet")
+	inputTrainsRegexp                 = regexp.MustCompile(`\A(?P<id>[a-zA-Z0-9_]+) (?P<start>([a-zA-Z0-9_]+|\*)) (?P<geschwindigkeit>[\d]+\.?[\d]*) (?P<kapazitaet>[\d]+)[\s]*\z`)
+	inputTrainsRegexpID               = inputTrainsRegexp.SubexpIndex("id")
+	inputTrainsRegexpStart            = inputTrainsRegexp.SubexpIndex("start")
+	inputTrainsRegexpGeschwindigkeit  = inputTrainsRegexp.SubexpIndex("geschwindigkeit")
+	inputTrainsRegexpKapazität        = inputTrainsRegexp.SubexpIndex("kapazitaet")
+	inputPassengersRegexp             = regexp.MustCompile(`\A(?P<id>[a-zA-Z0-9_]+) (?P<startbahnhof>[a-zA-Z0-9_]+) (?P<zielbahnhof>[a-zA-Z0-9_]+) (?P<gruppengroesse>[\d]+) (?P<ankunftszeit>[\d]+)[\s]*\z`)
+	inputPassengersRegexpID           = inputPassengersRegexp.SubexpIndex("id")
+	inputPassengersRegexpStartbahnhof = inputPassengersRegexp.SubexpIndex("startbahnhof")
+	inputPassengersRegexpZielbahnhof  = inputPassengersRegexp.SubexpIndex("zielbahnhof")
+	inputPassengersRegexpGruppengröße = inputPassengersRegexp.SubexpIndex("gruppengroesse")
+	inputPassengersRegexpAnkunftszeit = inputPassengersRegexp.SubexpIndex("ankunftszeit")
+)
+
+// ParseInput reads the input file at path.
+// All parsing errors are collected and returned as ParseErrors.
+func ParseInput(path string) (*World, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return &World{}, err
+	}
+	defer f.Close()
+	w, err := ParseInputReader(f)
+	var perr ParseErrors
+	if errors.As(err, &perr) {
+		perr.setFile(path)
+	}
+	return w, err
+}
+
+// ParseInputReader reads an input in the format of the input file from r.
+// All parsing errors are collected and returned as ParseErrors.
+func ParseInputReader(r io.Reader) (*World, error) {
+	w := World{
+		Lines:      make(map[string]*Line),
+		Stations:   make(map[string]*Station),
+		Trains:     make(map[string]*Train),
+		Passengers: make(map[string]*Passenger),
+	}
+
+	tempStationCurrentCount := make(map[string]*Int)
+	tempStationFirstUse := make(map[string]parseState)
+
+	currentInputMode := InputUnknown
+	var p parseState
+	var errs ParseErrors
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		s := scanner.Text()
+		p.line++
+		p.text = s
+		p.matches = nil
+		if strings.HasPrefix(s, "#") {
+			// Comment
+			continue
+		}
+		if s == "" {
+			// Empty line
+			continue
+		}
+		if s == "[Stations]" {
+			currentInputMode = InputStations
+			p.section = s
+			continue
+		}
+		if s == "[Lines]" {
+			currentInputMode = InputLines
+			p.section = s
+			continue
+		}
+		if s == "[Trains]" {
+			currentInputMode = InputTrains
+			p.section = s
+			continue
+		}
+		if s == "[Passengers]" {
+			currentInputMode = InputPassengers
+			p.section = s
+			continue
+		}
+
+		switch currentInputMode {
+		case InputUnknown:
+			errs = append(errs, p.errorf(ParseErrorMissingSection, "no prior definition found"))
+			continue
+		case InputLines:
+			if !p.match(inputLinesRegexp) {
+				errs = append(errs, p.errorf(ParseErrorSyntax, "not matching definition for line"))
+				continue
+			}
+			var l Line
+			l.ID = p.value(inputLinesRegexpID)
+			if l.ID == "" {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputLinesRegexpID, "invalid id"))
+				continue
+			}
+			l.End = make([]string, 2)
+			l.End[0] = p.value(inputLinesRegexpAnfang)
+			l.End[1] = p.value(inputLinesRegexpEnde)
+			if l.End[0] == "" {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputLinesRegexpAnfang, "invalid start"))
+				continue
+			}
+			if l.End[1] == "" {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputLinesRegexpEnde, "invalid end"))
+				continue
+			}
+			if l.End[0] == l.End[1] {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputLinesRegexpEnde, "start and end same station"))
+				continue
+			}
+			length, ok := new(Rat).SetString(p.value(inputLinesRegexpLänge))
+			if !ok {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputLinesRegexpLänge, "can not parse length"))
+				continue
+			}
+			l.Length = *length
+			capacity, ok := new(Int).SetString(p.value(inputLinesRegexpKapazität), 10)
+			if !ok {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputLinesRegexpKapazität, "can not parse capacity"))
+				continue
+			}
+			l.MaxCapacity = *capacity
+
+			_, ok = w.Lines[l.ID]
+			if ok {
+				errs = append(errs, p.errorField(ParseErrorDuplicate, inputLinesRegexpID, "id found twice"))
+				continue
+			}
+
+			w.Lines[l.ID] = &l
+		case InputStations:
+			var st Station
+			if !p.match(inputStationsRegexp) {
+				errs = append(errs, p.errorf(ParseErrorSyntax, "not matching definition for line"))
+				continue
+			}
+			st.ID = p.value(inputStationsRegexpID)
+			if st.ID == "" {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputStationsRegexpID, "invalid id"))
+				continue
+			}
+			capacity, ok := new(Int).SetString(p.value(inputStationsRegexpKapazität), 10)
+			if !ok {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputStationsRegexpKapazität, "invalid capacity"))
+				continue
+			}
+			st.Capacity = *capacity
+			_, ok = w.Stations[st.ID]
+			if ok {
+				errs = append(errs, p.errorField(ParseErrorDuplicate, inputStationsRegexpID, "id found twice"))
+				continue
+			}
+			w.Stations[st.ID] = &st
+		case InputTrains:
+			if !p.match(inputTrainsRegexp) {
+				errs = append(errs, p.errorf(ParseErrorSyntax, "not matching definition for line"))
+				continue
+			}
+			var t Train
+
+			t.ID = p.value(inputTrainsRegexpID)
+			if t.ID == "" {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputTrainsRegexpID, "invalid id"))
+				continue
+			}
+			capacity, ok := new(Int).SetString(p.value(inputTrainsRegexpKapazität), 10)
+			if !ok {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputTrainsRegexpKapazität, "invalid capacity"))
+				continue
+			}
+			t.Capacity = *capacity
+			speed, ok := new(Rat).SetString(p.value(inputTrainsRegexpGeschwindigkeit))
+			if !ok {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputTrainsRegexpGeschwindigkeit, "invalid speed"))
+				continue
+			}
+			t.Speed = *speed
+			t.Position = []string{p.value(inputTrainsRegexpStart)}
+			if t.Position[0] == "" {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputTrainsRegexpStart, "invalid position"))
+				continue
+			}
+			if t.Position[0] == "*" {
+				t.PositionType = TrainPositionWildcard
+				t.Wildcard = true
+			} else {
+				t.PositionType = TrainPositionStation
+				c := tempStationCurrentCount[t.Position[0]]
+				if c == nil {
+					c = NewInt(0)
+					tempStationCurrentCount[t.Position[0]] = c
+					tempStationFirstUse[t.Position[0]] = p
+				}
+				c.Add(c, NewInt(1))
+			}
+			_, ok = w.Trains[t.ID]
+			if ok {
+				errs = append(errs, p.errorField(ParseErrorDuplicate, inputTrainsRegexpID, "id found twice"))
+				continue
+			}
+			w.Trains[t.ID] = &t
+		case InputPassengers:
+			if !p.match(inputPassengersRegexp) {
+				errs = append(errs, p.errorf(ParseErrorSyntax, "not matching definition for line"))
+				continue
+			}
+			var ps Passenger
+
+			ps.ID = p.value(inputPassengersRegexpID)
+			if ps.ID == "" {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputPassengersRegexpID, "invalid id"))
+				continue
+			}
+
+			ps.Start = p.value(inputPassengersRegexpStartbahnhof)
+			if ps.Start == "" {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputPassengersRegexpStartbahnhof, "invalid start"))
+				continue
+			}
+
+			ps.Target = p.value(inputPassengersRegexpZielbahnhof)
+			if ps.Target == "" {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputPassengersRegexpZielbahnhof, "invalid target"))
+				continue
+			}
+
+			size, ok := new(Int).SetString(p.value(inputPassengersRegexpGruppengröße), 10)
+			if !ok {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputPassengersRegexpGruppengröße, "invalid size"))
+				continue
+			}
+			ps.Size = *size
+
+			targetTime, ok := new(Int).SetString(p.value(inputPassengersRegexpAnkunftszeit), 10)
+			if !ok {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputPassengersRegexpAnkunftszeit, "invalid target time"))
+				continue
+			}
+			ps.TargetTime = *targetTime
+
+			ps.PositionType = PassengerPositionStation
+
+			ps.Position = p.value(inputPassengersRegexpStartbahnhof)
+			if ps.Position == "" {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, inputPassengersRegexpStartbahnhof, "invalid start"))
+				continue
+			}
+
+			_, ok = w.Passengers[ps.ID]
+			if ok {
+				errs = append(errs, p.errorField(ParseErrorDuplicate, inputPassengersRegexpID, "id found twice"))
+				continue
+			}
+			w.Passengers[ps.ID] = &ps
+		default:
+			errs = append(errs, p.errorf(ParseErrorInternal, "[internal] unknown current state"))
+			continue
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return &w, err
+	}
+
+	// Iterate in a fixed order so that errors are reported deterministically
+	stationIDs := make([]string, 0, len(tempStationCurrentCount))
+	for k := range tempStationCurrentCount {
+		stationIDs = append(stationIDs, k)
+	}
+	SortIDs(stationIDs)
+	for _, k := range stationIDs {
+		s, ok := w.Stations[k]
+		if !ok {
+			p := tempStationFirstUse[k]
+			errs = append(errs, p.errorField(ParseErrorUnknownReference, inputTrainsRegexpStart, "can not assign current number of trains to non-existing station '%s'", k))
+			continue
+		}
+		s.CurrenTrains.Add(&s.CurrenTrains, tempStationCurrentCount[k])
+	}
+
+	w.network = NewNetwork(&w)
+	return &w, errs.errorOrNil()
+}
@@ -0,0 +1,264 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type PlanState int
+
+const (
+	PlanUnknown PlanState = iota
+	PlanPassenger
+	PlanTrain
+	PlanInvalid
+)
+
+var (
+	trainPlanRegexp           = regexp.MustCompile(`\A(?P<time>[\d]+) (?P<action>(Start)|(Depart)) (?P<id>[a-zA-Z0-9_]+)[\s]*\z`)
+	trainPlanRegexpTime       = trainPlanRegexp.SubexpIndex("time")
+	trainPlanRegexpAction     = trainPlanRegexp.SubexpIndex("action")
+	trainPlanRegexpID         = trainPlanRegexp.SubexpIndex("id")
+	passengerPlanRegexp       = regexp.MustCompile(`\A(?P<time>[\d]+) (?P<action>(Board)|(Detrain)) ?(?P<id>[a-zA-Z0-9_]+)?[\s]*\z`)
+	passengerPlanRegexpTime   = passengerPlanRegexp.SubexpIndex("time")
+	passengerPlanRegexpAction = passengerPlanRegexp.SubexpIndex("action")
+	passengerPlanRegexpID     = passengerPlanRegexp.SubexpIndex("id")
+)
+
+// ParsePlan reads the plan file at path into w.
+// All parsing errors are collected and returned as ParseErrors.
+func ParsePlan(w *World, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	err = ParsePlanReader(w, f)
+	var perr ParseErrors
+	if errors.As(err, &perr) {
+		perr.setFile(path)
+	}
+	return err
+}
+
+// ParsePlanReader reads a plan in the format of the output file from r into w.
+// All parsing errors are collected and returned as ParseErrors.
+func ParsePlanReader(w *World, r io.Reader) error {
+	currentState := PlanUnknown
+	currentID := ""
+	var p parseState
+	var errs ParseErrors
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		s := scanner.Text()
+		p.line++
+		p.text = s
+		p.matches = nil
+		if strings.HasPrefix(s, "#") {
+			// Comment
+			continue
+		}
+		if s == "" {
+			// Empty line
+			continue
+		}
+		if strings.HasPrefix(s, "[") {
+			s = strings.TrimSpace(s)
+			p.section = s
+			s = strings.TrimPrefix(s, "[")
+			s = strings.TrimSuffix(s, "]")
+			split := strings.Split(s, ":")
+			if len(split) != 2 {
+				errs = append(errs, p.errorf(ParseErrorSyntax, "can not parse section header"))
+				currentState = PlanInvalid
+				continue
+			}
+			currentID = split[1]
+			if split[0] == "Train" {
+				currentState = PlanTrain
+			} else if split[0] == "Passenger" {
+				currentState = PlanPassenger
+			} else {
+				errs = append(errs, p.errorf(ParseErrorUnknownSection, "unknown type '%s'", split[0]))
+				currentState = PlanInvalid
+			}
+			continue
+		}
+
+		switch currentState {
+		case PlanUnknown:
+			errs = append(errs, p.errorf(ParseErrorMissingSection, "no prior definition found"))
+			continue
+		case PlanInvalid:
+			// Errors in this section have already been reported
+			continue
+		case PlanPassenger:
+			if !p.match(passengerPlanRegexp) {
+				errs = append(errs, p.errorf(ParseErrorSyntax, "not matching definition for line"))
+				continue
+			}
+			time, ok := new(Int).SetString(p.value(passengerPlanRegexpTime), 10)
+			if !ok {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, passengerPlanRegexpTime, "can not parse time"))
+				continue
+			}
+			if time.Sign() != +1 {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, passengerPlanRegexpTime, "time '%s' must be positive", time.String()))
+				continue
+			}
+			ps, ok := w.Passengers[currentID]
+			if !ok {
+				errs = append(errs, p.errorf(ParseErrorUnknownReference, "no valid passenger id (%s)", currentID))
+				// Only report the unknown id once per section
+				currentState = PlanInvalid
+				continue
+			}
+			action := PassengerAction{Time: *time}
+			switch p.value(passengerPlanRegexpAction) {
+			case "Board":
+				action.Type = PassengerActionBoard
+				action.Train = p.value(passengerPlanRegexpID)
+				if action.Train == "" {
+					errs = append(errs, p.errorField(ParseErrorInvalidValue, passengerPlanRegexpAction, "'Board' needs a train"))
+					continue
+				}
+			case "Detrain":
+				action.Type = PassengerActionDetrain
+			}
+			if !ps.AddAction(action) {
+				errs = append(errs, p.errorField(ParseErrorDuplicate, passengerPlanRegexpTime, "time %s already in plan", time.String()))
+				continue
+			}
+
+			if time.Cmp(&w.MaxTime) == +1 {
+				maxtime := new(Int).Set(time)
+				maxtime.Add(maxtime, NewInt(1))
+				w.MaxTime = *maxtime
+			}
+		case PlanTrain:
+			if !p.match(trainPlanRegexp) {
+				errs = append(errs, p.errorf(ParseErrorSyntax, "not matching definition for line"))
+				continue
+			}
+			time, ok := new(Int).SetString(p.value(trainPlanRegexpTime), 10)
+			if !ok {
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, trainPlanRegexpTime, "can not parse time"))
+				continue
+			}
+			t, ok := w.Trains[currentID]
+			if !ok {
+				errs = append(errs, p.errorf(ParseErrorUnknownReference, "no valid train id (%s)", currentID))
+				// Only report the unknown id once per section
+				currentState = PlanInvalid
+				continue
+			}
+			switch time.Cmp(NewInt(0)) {
+			case +1:
+				if p.value(trainPlanRegexpAction) != "Depart" {
+					errs = append(errs, p.errorField(ParseErrorInvalidValue, trainPlanRegexpAction, "'%s' is only allowed at time 0", p.value(trainPlanRegexpAction)))
+					continue
+				}
+				if !t.AddAction(TrainAction{Time: *time, Type: TrainActionDepart, Target: p.value(trainPlanRegexpID)}) {
+					errs = append(errs, p.errorField(ParseErrorDuplicate, trainPlanRegexpTime, "time %s already in plan", time.String()))
+					continue
+				}
+
+				if time.Cmp(&w.MaxTime) == +1 {
+					maxtime := new(Int).Set(time)
+					maxtime.Add(maxtime, NewInt(1))
+					w.MaxTime = *maxtime
+				}
+			case 0:
+				if p.value(trainPlanRegexpAction) != "Start" {
+					errs = append(errs, p.errorField(ParseErrorInvalidValue, trainPlanRegexpAction, "time %s must be 'Start'", time.String()))
+					continue
+				}
+				if t.PositionType != TrainPositionWildcard || len(t.Position) != 1 || t.Position[0] != "*" {
+					errs = append(errs, p.errorField(ParseErrorInvalidValue, trainPlanRegexpAction, "train must be at '*' for 'Start'"))
+					continue
+				}
+				st, ok := w.Stations[p.value(trainPlanRegexpID)]
+				if !ok {
+					errs = append(errs, p.errorField(ParseErrorUnknownReference, trainPlanRegexpID, "station '%s' does not exist", p.value(trainPlanRegexpID)))
+					continue
+				}
+				t.Position = []string{st.ID}
+				t.PositionType = TrainPositionStation
+				st.CurrenTrains.Add(&st.CurrenTrains, NewInt(1))
+			case -1:
+				errs = append(errs, p.errorField(ParseErrorInvalidValue, trainPlanRegexpTime, "time '%s' must be positive", time.String()))
+				continue
+			}
+		default:
+			errs = append(errs, p.errorf(ParseErrorInternal, "[internal] unknown current state"))
+			continue
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errs.errorOrNil()
+}
+
+// ParseCombinedReader reads a single document from r which contains an input followed by a plan.
+// The plan starts with the first section header which is not part of the input format (e.g. '[Train:T1]').
+func ParseCombinedReader(r io.Reader) (*World, error) {
+	var input, plan bytes.Buffer
+	current := &input
+	inputLines := 0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		s := scanner.Text()
+		if current == &input && strings.HasPrefix(s, "[") {
+			switch strings.TrimSpace(s) {
+			case "[Stations]", "[Lines]", "[Trains]", "[Passengers]":
+			default:
+				current = &plan
+			}
+		}
+		if current == &input {
+			inputLines++
+		}
+		current.WriteString(s)
+		current.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return &World{}, err
+	}
+
+	w, err := ParseInputReader(&input)
+	if err != nil {
+		return w, err
+	}
+	err = ParsePlanReader(w, &plan)
+	var perr ParseErrors
+	if errors.As(err, &perr) {
+		// Report line numbers relative to the combined document
+		for i := range perr {
+			perr[i].Line += inputLines
+		}
+	}
+	return w, err
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestParseCombinedReader(t *testing.T) {
+	input, err := os.ReadFile(path.Join("..", "test", "simple", "input.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := os.ReadFile(path.Join("..", "test", "simple", "output.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ParseCombinedReader(strings.NewReader(string(input) + string(plan)))
+	if err != nil {
+		t.Fatal("can not parse combined document:", err)
+	}
+	if len(w.Stations) != 3 || len(w.Lines) != 2 || len(w.Trains) != 2 || len(w.Passengers) != 2 {
+		t.Fatal("wrong number of elements parsed")
+	}
+	if len(w.Trains["T2"].Plan) != 1 || w.Trains["T2"].Position[0] != "S2" {
+		t.Error("plan of T2 not parsed")
+	}
+
+	result := w.Run(Options{})
+	if !result.Valid || result.Delay.String() != "9" {
+		t.Error("wrong result:", result.Delay, result.Errors)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	w, err := ParseInput(path.Join("..", "test", "simple", "input.txt"))
+	if err != nil {
+		t.Fatal("can not read input:", err)
+	}
+
+	err = ParsePlanReader(w, strings.NewReader("[Train:T1]\n\n1 Depart L1\n1 Depart L2\n"))
+	var perrs ParseErrors
+	if !errors.As(err, &perrs) || len(perrs) != 1 {
+		t.Fatalf("expected one ParseError, got %v", err)
+	}
+	perr := perrs[0]
+	if perr.Line != 4 || perr.Column != 1 || perr.Field != "time" || perr.Section != "[Train:T1]" || perr.Kind != ParseErrorDuplicate || perr.Text != "1 Depart L2" {
+		t.Errorf("wrong error: %#v", perr)
+	}
+	if !strings.HasPrefix(perr.Error(), "4:1: [Train:T1] ") {
+		t.Errorf("wrong error format: %s", perr.Error())
+	}
+}
+
+func TestParseErrorsCollected(t *testing.T) {
+	w, err := ParseInput(path.Join("..", "test", "simple", "input.txt"))
+	if err != nil {
+		t.Fatal("can not read input:", err)
+	}
+
+	plan := "[Train:T1]\n1 Depart\nx Depart L1\n[Train:T9]\n1 Depart L1\n2 Depart L1\n[Passenger:P1]\n-1 Board T1\n" +
+		"[Train:T2]\n3 Start S1\n[Passenger:P2]\n4 Board\n"
+	err = ParsePlanReader(w, strings.NewReader(plan))
+	var perrs ParseErrors
+	if !errors.As(err, &perrs) {
+		t.Fatalf("expected ParseErrors, got %v", err)
+	}
+	// The unknown train T9 is only reported once
+	lines := []int{2, 3, 5, 8, 10, 12}
+	if len(perrs) != len(lines) {
+		t.Fatalf("expected %d errors, got %d: %v", len(lines), len(perrs), perrs)
+	}
+	for i := range lines {
+		if perrs[i].Line != lines[i] {
+			t.Errorf("error %d: expected line %d, got %d", i, lines[i], perrs[i].Line)
+		}
+	}
+}
@@ -13,18 +13,16 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package simulator
 
 import (
 	"fmt"
-	"math/big"
-	"regexp"
 	"sync"
 )
 
 type PassengerPosition int
 
-var InvalidDelay = big.NewInt(-1)
+var InvalidDelay = NewInt(-1)
 
 const (
 	PassengerPositionUnknown PassengerPosition = iota
@@ -36,27 +34,35 @@ type Passenger struct {
 	ID            string
 	Start         string
 	Target        string
-	Size          big.Int
-	TargetTime    big.Int
-	TargetReached big.Int
+	Size          Int
+	TargetTime    Int
+	TargetReached Int
 	PositionType  PassengerPosition
 	Position      string
-	Plan          map[string]string
+	Plan          []PassengerAction
+	Legs          []Leg
+
+	nextAction int
 }
 
-var passengerPlanRegexp = regexp.MustCompile(`\A(?P<time>[\d]+) (?P<action>(Board)|(Detrain)) ?(?P<id>[a-zA-Z0-9_]+)?[\s]*\z`)
-var passengerPlanRegexpTime = trainPlanRegexp.SubexpIndex("time")
-var passengerPlanRegexpAction = trainPlanRegexp.SubexpIndex("action")
-var passengerPlanRegexpID = trainPlanRegexp.SubexpIndex("id")
+// Leg is a part of the journey of a passenger on a single train.
+// To is empty and Detrain is 0 while the passenger is still on the train.
+type Leg struct {
+	Train   string
+	From    string
+	Board   Int
+	To      string
+	Detrain Int
+}
 
-func (p *Passenger) Delay() *big.Int {
-	if p.TargetReached.Cmp(big.NewInt(0)) == 0 {
+func (p *Passenger) Delay() *Int {
+	if p.TargetReached.Sign() == 0 {
 		return InvalidDelay
 	}
-	delay := big.NewInt(0)
+	delay := NewInt(0)
 	delay.Sub(&p.TargetReached, &p.TargetTime)
-	if delay.Cmp(big.NewInt(0)) == -1 {
-		return big.NewInt(0)
+	if delay.Sign() == -1 {
+		return NewInt(0)
 	}
 	delay.Mul(delay, &p.Size)
 	return delay
@@ -71,11 +77,11 @@ func (p *Passenger) IsValidStart(w *World) error {
 	if !ok {
 		return fmt.Errorf("passenger (%s): unknown start '%s'", p.ID, p.Target)
 	}
-	if p.Size.Cmp(big.NewInt(0)) != +1 {
+	if p.Size.Sign() != +1 {
 		return fmt.Errorf("passenger (%s): size '%s' must be positive", p.ID, p.Size.String())
 	}
 
-	if p.TargetTime.Cmp(big.NewInt(0)) != +1 {
+	if p.TargetTime.Sign() != +1 {
 		return fmt.Errorf("passenger (%s): target time '%s' must be positive", p.ID, p.Size.String())
 	}
 	return p.IsValid(w)
@@ -103,75 +109,85 @@ func (p *Passenger) IsValid(w *World) error {
 func (p *Passenger) Update(w *World, e chan error, wg *sync.WaitGroup) {
 	defer wg.Done()
 
-	plan, ok := p.Plan[w.CurrentTime.String()]
-
-	if !ok {
-		// Nothing to do here
-		return
+	err := p.update(w)
+	if err != nil {
+		e <- newSimulationError(w, EntityPassenger, p.ID, err)
 	}
+}
+
+// update executes the plan of the passenger for the current time.
+// If an error is returned, the passenger is not changed.
+func (p *Passenger) update(w *World) error {
+	action := p.currentAction(w)
 
-	p.TargetReached = big.Int{}
-	matches := passengerPlanRegexp.FindStringSubmatch(plan)
-	if matches == nil {
-		e <- fmt.Errorf("passenger (%s): can not match rule '%s'", p.ID, plan)
-		return
+	if action == nil {
+		// Nothing to do here
+		return nil
 	}
 
-	switch matches[passengerPlanRegexpAction] {
-	case "Board":
+	switch action.Type {
+	case PassengerActionBoard:
 		if p.PositionType == PassengerPositionTrain {
-			e <- fmt.Errorf("passenger (%s): can not board, already on a train", p.ID)
-			return
+			return fmt.Errorf("passenger (%s): can not board, already on a train", p.ID)
 		}
-		train, ok := w.Trains[matches[passengerPlanRegexpID]]
+		train, ok := w.Trains[action.Train]
 		if !ok {
-			e <- fmt.Errorf("passenger (%s): can not find train %s", p.ID, matches[passengerPlanRegexpID])
-			return
+			return fmt.Errorf("passenger (%s): can not find train %s", p.ID, action.Train)
 		}
 		train.L.Lock()
 		defer train.L.Unlock()
 		if !train.BoardingPossible {
-			e <- fmt.Errorf("passenger (%s): boarding not possible at %s", p.ID, train.ID)
-			return
+			return fmt.Errorf("passenger (%s): boarding not possible at %s", p.ID, train.ID)
 		}
 		if train.Position[0] != p.Position {
-			e <- fmt.Errorf("passenger (%s): train is not at station %s (currently: %s)", p.ID, p.Position, train.Position[0])
-			return
+			return fmt.Errorf("passenger (%s): train is not at station %s (currently: %s)", p.ID, p.Position, train.Position[0])
 		}
 		train.Passengers.Add(&train.Passengers, &p.Size)
+		leg := Leg{Train: train.ID, From: p.Position}
+		leg.Board.Set(&w.CurrentTime)
+		p.Legs = append(p.Legs, leg)
+		w.markChanged(EntityPassenger, p.ID)
+		w.markChanged(EntityTrain, train.ID)
+		w.record(EntityPassenger, p.ID, Event{Type: EventPassengerBoard, Passenger: p.ID, Train: train.ID, Station: p.Position})
 		p.PositionType = PassengerPositionTrain
 		p.Position = train.ID
-		p.TargetReached = big.Int{}
-	case "Detrain":
+		p.TargetReached = Int{}
+	case PassengerActionDetrain:
 		if p.PositionType == PassengerPositionStation {
-			e <- fmt.Errorf("passenger (%s): can not detrain, already at a station", p.ID)
-			return
+			return fmt.Errorf("passenger (%s): can not detrain, already at a station", p.ID)
 		}
 		train, ok := w.Trains[p.Position]
 		if !ok {
-			e <- fmt.Errorf("passenger (%s): can not find train '%s'", p.ID, p.Position)
-			return
+			return fmt.Errorf("passenger (%s): can not find train '%s'", p.ID, p.Position)
 		}
 		train.L.Lock()
 		defer train.L.Unlock()
 		if !train.BoardingPossible {
-			e <- fmt.Errorf("passenger (%s): detrain not possible at %s", p.ID, train.ID)
-			return
+			return fmt.Errorf("passenger (%s): detrain not possible at %s", p.ID, train.ID)
 		}
 		station, ok := w.Stations[train.Position[0]]
 		if !ok {
-			e <- fmt.Errorf("passenger (%s): can not find station %s", p.ID, train.Position[0])
-			return
+			return fmt.Errorf("passenger (%s): can not find station %s", p.ID, train.Position[0])
 		}
 		train.Passengers.Sub(&train.Passengers, &p.Size)
 		p.PositionType = PassengerPositionStation
 		p.Position = station.ID
+		if len(p.Legs) != 0 {
+			leg := &p.Legs[len(p.Legs)-1]
+			leg.To = station.ID
+			leg.Detrain.Set(&w.CurrentTime)
+		}
+		p.TargetReached = Int{}
+		w.markChanged(EntityPassenger, p.ID)
+		w.markChanged(EntityTrain, train.ID)
+		w.record(EntityPassenger, p.ID, Event{Type: EventPassengerDetrain, Passenger: p.ID, Train: train.ID, Station: station.ID})
 		if p.Position == p.Target {
-			targetTime := new(big.Int).Set(&w.CurrentTime)
+			targetTime := new(Int).Set(&w.CurrentTime)
 			p.TargetReached = *targetTime
+			w.record(EntityPassenger, p.ID, Event{Type: EventTargetReached, Passenger: p.ID, Station: station.ID})
 		}
 	default:
-		e <- fmt.Errorf("passenger (%s): unknown action '%s'", p.ID, matches[passengerPlanRegexpAction])
-		return
+		return fmt.Errorf("passenger (%s): unknown action '%s'", p.ID, action.Type.String())
 	}
+	return nil
 }
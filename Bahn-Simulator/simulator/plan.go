@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"sort"
+)
+
+type TrainActionType int
+
+const (
+	TrainActionUnknown TrainActionType = iota
+	TrainActionStart
+	TrainActionDepart
+)
+
+func (t TrainActionType) String() string {
+	switch t {
+	case TrainActionStart:
+		return "Start"
+	case TrainActionDepart:
+		return "Depart"
+	default:
+		return "Unknown"
+	}
+}
+
+// TrainAction is a single entry of the plan of a train.
+// Target is the line for TrainActionDepart and the station for TrainActionStart.
+type TrainAction struct {
+	Time   Int
+	Type   TrainActionType
+	Target string
+}
+
+func (a *TrainAction) String() string {
+	return a.Time.String() + " " + a.Type.String() + " " + a.Target
+}
+
+type PassengerActionType int
+
+const (
+	PassengerActionUnknown PassengerActionType = iota
+	PassengerActionBoard
+	PassengerActionDetrain
+)
+
+func (t PassengerActionType) String() string {
+	switch t {
+	case PassengerActionBoard:
+		return "Board"
+	case PassengerActionDetrain:
+		return "Detrain"
+	default:
+		return "Unknown"
+	}
+}
+
+// PassengerAction is a single entry of the plan of a passenger.
+// Train is only set for PassengerActionBoard.
+type PassengerAction struct {
+	Time  Int
+	Type  PassengerActionType
+	Train string
+}
+
+func (a *PassengerAction) String() string {
+	if a.Type == PassengerActionBoard {
+		return a.Time.String() + " " + a.Type.String() + " " + a.Train
+	}
+	return a.Time.String() + " " + a.Type.String()
+}
+
+// AddAction adds a to the plan of t, keeping the plan sorted by time.
+// It returns false if the plan already contains an action at the same time.
+// MaxTime of the world is not changed, see World.UpdateMaxTime.
+func (t *Train) AddAction(a TrainAction) bool {
+	i := sort.Search(len(t.Plan), func(i int) bool { return t.Plan[i].Time.Cmp(&a.Time) != -1 })
+	if i < len(t.Plan) && t.Plan[i].Time.Cmp(&a.Time) == 0 {
+		return false
+	}
+	t.Plan = append(t.Plan, TrainAction{})
+	copy(t.Plan[i+1:], t.Plan[i:])
+	t.Plan[i] = a
+	return true
+}
+
+// AddAction adds a to the plan of p, keeping the plan sorted by time.
+// It returns false if the plan already contains an action at the same time.
+// MaxTime of the world is not changed, see World.UpdateMaxTime.
+func (p *Passenger) AddAction(a PassengerAction) bool {
+	i := sort.Search(len(p.Plan), func(i int) bool { return p.Plan[i].Time.Cmp(&a.Time) != -1 })
+	if i < len(p.Plan) && p.Plan[i].Time.Cmp(&a.Time) == 0 {
+		return false
+	}
+	p.Plan = append(p.Plan, PassengerAction{})
+	copy(p.Plan[i+1:], p.Plan[i:])
+	p.Plan[i] = a
+	return true
+}
+
+// UpdateMaxTime sets MaxTime of w to one timestep after the last planned action, so that all actions are simulated.
+// It has to be called after actions were added with AddAction.
+func (w *World) UpdateMaxTime() {
+	w.MaxTime = Int{}
+	last := new(Int)
+	for _, t := range w.Trains {
+		if len(t.Plan) != 0 && t.Plan[len(t.Plan)-1].Time.Cmp(last) == +1 {
+			last.Set(&t.Plan[len(t.Plan)-1].Time)
+		}
+	}
+	for _, p := range w.Passengers {
+		if len(p.Plan) != 0 && p.Plan[len(p.Plan)-1].Time.Cmp(last) == +1 {
+			last.Set(&p.Plan[len(p.Plan)-1].Time)
+		}
+	}
+	if last.Sign() == +1 {
+		w.MaxTime.Add(last, NewInt(1))
+	}
+}
+
+// currentAction returns the action of the train planned for the current time of w, or nil.
+// Actions are consumed in order, so the plan must not be changed during a simulation.
+func (t *Train) currentAction(w *World) *TrainAction {
+	for t.nextAction < len(t.Plan) && t.Plan[t.nextAction].Time.Cmp(&w.CurrentTime) == -1 {
+		t.nextAction++
+	}
+	if t.nextAction < len(t.Plan) && t.Plan[t.nextAction].Time.Cmp(&w.CurrentTime) == 0 {
+		t.nextAction++
+		return &t.Plan[t.nextAction-1]
+	}
+	return nil
+}
+
+// currentAction returns the action of the passenger planned for the current time of w, or nil.
+// Actions are consumed in order, so the plan must not be changed during a simulation.
+func (p *Passenger) currentAction(w *World) *PassengerAction {
+	for p.nextAction < len(p.Plan) && p.Plan[p.nextAction].Time.Cmp(&w.CurrentTime) == -1 {
+		p.nextAction++
+	}
+	if p.nextAction < len(p.Plan) && p.Plan[p.nextAction].Time.Cmp(&w.CurrentTime) == 0 {
+		p.nextAction++
+		return &p.Plan[p.nextAction-1]
+	}
+	return nil
+}
+
+// trainPlan returns the plan of t including the 'Start' action of wildcard trains placed by ParsePlan.
+func trainPlan(t *Train) []TrainAction {
+	if !t.Wildcard || t.PositionType != TrainPositionStation || (len(t.Plan) != 0 && t.Plan[0].Type == TrainActionStart) {
+		return t.Plan
+	}
+	plan := make([]TrainAction, 0, len(t.Plan)+1)
+	plan = append(plan, TrainAction{Type: TrainActionStart, Target: t.Position[0]})
+	return append(plan, t.Plan...)
+}
@@ -0,0 +1,743 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// RepairChange is a single fix applied by Repair.
+type RepairChange struct {
+	// Violation is the first error of the simulation before the fix.
+	Violation *SimulationError
+	// Fix describes the change of the plan.
+	Fix string
+}
+
+func (c RepairChange) String() string {
+	return c.Violation.Error() + ": " + c.Fix
+}
+
+// Repair simulates plan and fixes the first rule violation until the plan is valid.
+// The returned plan is written by WritePlan, the changes are in the order they were applied.
+//
+// Fixes are minimal changes of single actions:
+//   - Departures of trains still on a line are delayed until the train arrives.
+//   - Departures to a full station or line are delayed until another train left it, or dropped if no train leaves.
+//   - Departures to unknown or unconnected lines are dropped.
+//   - Wildcard trains without 'Start' are started at an end of the line of their first departure.
+//   - Boarding is moved to the closest time at which the train stops at the station of the passenger and still
+//     reaches the station of the following detraining (or the target) afterwards; the detraining is moved to the
+//     arrival there. Other trains are used if the planned train does not reach it. If no train can be used or the
+//     passenger is already on a train, the leg (boarding and the following detraining) is dropped. Boarding a full
+//     train is delayed to a later stop at the station in the same way.
+//   - Boarding while riding a train without a planned detraining is replaced by the missing detraining.
+//   - Detraining is moved to the closest stop of the train at the station needed for the next leg or the target.
+//   - Passengers which do not reach their target detrain at the next stop of their last train at the target.
+//
+// Actions following a delayed action are delayed as well if necessary to keep the plan in order.
+// An error is returned if input or plan can not be parsed or a violation can not be fixed.
+// The changes applied so far are returned in the latter case.
+func Repair(input, plan []byte) ([]byte, []RepairChange, error) {
+	w, err := ParseInputReader(bytes.NewReader(input))
+	if err != nil {
+		return nil, nil, err
+	}
+	err = ParsePlanReader(w, bytes.NewReader(plan))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// w keeps the state at time 0 and is only changed by the fixes, copies of it are simulated
+	main := new(repairRun)
+	moves := &repairRun{trace: true}
+	from, movesFrom := new(Int), new(Int)
+	var changes []RepairChange
+	for {
+		sim, r, err := main.run(w, from)
+		if err != nil {
+			return nil, changes, err
+		}
+		if r.Valid {
+			break
+		}
+		var violation *SimulationError
+		if !errors.As(r.Errors[0], &violation) {
+			return nil, changes, r.Errors[0]
+		}
+		if len(changes) == maxRepairChanges {
+			return nil, changes, fmt.Errorf("can not repair %s: too many changes", violation.Error())
+		}
+		if movesFrom != nil {
+			_, _, err = moves.run(w, movesFrom)
+			if err != nil {
+				return nil, changes, err
+			}
+		}
+
+		before := savePlans(w)
+		rp := &repairer{w: w, sim: sim, events: moves.events}
+		fix, ok := rp.fix(violation)
+		if !ok {
+			return nil, changes, fmt.Errorf("can not repair %s", violation.Error())
+		}
+		changes = append(changes, RepairChange{Violation: violation, Fix: fix})
+		w.UpdateMaxTime()
+		from, movesFrom = before.changedSince(w)
+	}
+
+	var out bytes.Buffer
+	err = WritePlan(w, &out)
+	return out.Bytes(), changes, err
+}
+
+// maxRepairChanges is the maximal number of fixes applied by Repair.
+const maxRepairChanges = 1000
+
+// repairCheckpointInterval is the number of timesteps between the checkpoints of a repairRun.
+const repairCheckpointInterval = 128
+
+// repairRun simulates copies of a world while its plan is repaired. Checkpoints of the last simulation are kept
+// so that the next simulation continues before the first change of the plan instead of starting at time 0.
+type repairRun struct {
+	// trace collects all train movements in events and skips actions violating a rule (KeepGoing)
+	trace  bool
+	events []Event
+
+	checkpoints []*Checkpoint
+}
+
+// run simulates a copy of w. The plan of w must be unchanged before time from since the last run.
+func (rr *repairRun) run(w *World, from *Int) (*World, Result, error) {
+	for len(rr.checkpoints) != 0 && rr.checkpoints[len(rr.checkpoints)-1].Snapshot.Time.Cmp(from) != -1 {
+		rr.checkpoints = rr.checkpoints[:len(rr.checkpoints)-1]
+	}
+
+	opt := Options{}
+	if rr.trace {
+		opt.KeepGoing = true
+		opt.Trace = func(e Event) {
+			switch e.Type {
+			case EventTrainDepart, EventTrainArrive:
+				rr.events = append(rr.events, e)
+			}
+		}
+	}
+
+	sim := w.clone()
+	var s *Simulation
+	if len(rr.checkpoints) == 0 {
+		rr.events = rr.events[:0]
+		s = sim.NewSimulation(opt)
+	} else {
+		c := rr.checkpoints[len(rr.checkpoints)-1]
+		i := sort.Search(len(rr.events), func(i int) bool { return rr.events[i].Time.Cmp(&c.Snapshot.Time) == +1 })
+		rr.events = rr.events[:i]
+		var err error
+		s, err = sim.ResumeSimulation(opt, c)
+		if err != nil {
+			return nil, Result{}, err
+		}
+	}
+	for steps := 1; s.Step(); steps++ {
+		if steps%repairCheckpointInterval == 0 {
+			rr.checkpoints = append(rr.checkpoints, s.Checkpoint())
+		}
+	}
+	return sim, s.Result(), nil
+}
+
+// repairPlans is a copy of the plans and start positions of a world, see savePlans.
+type repairPlans struct {
+	trains     map[string]*Train
+	passengers map[string][]PassengerAction
+}
+
+func savePlans(w *World) repairPlans {
+	p := repairPlans{trains: make(map[string]*Train, len(w.Trains)), passengers: make(map[string][]PassengerAction, len(w.Passengers))}
+	for k, t := range w.Trains {
+		p.trains[k] = &Train{Position: append([]string(nil), t.Position...), PositionType: t.PositionType, Plan: cloneTrainPlan(t.Plan)}
+	}
+	for k, pa := range w.Passengers {
+		p.passengers[k] = clonePassengerPlan(pa.Plan)
+	}
+	return p
+}
+
+// changedSince returns the time of the first change of the plans of w since p was saved.
+// trains is the time of the first change of a train and nil if no train was changed.
+func (p repairPlans) changedSince(w *World) (all, trains *Int) {
+	first := func(current, t *Int) *Int {
+		if t != nil && (current == nil || t.Cmp(current) == -1) {
+			return new(Int).Set(t)
+		}
+		return current
+	}
+	for k, t := range w.Trains {
+		old := p.trains[k]
+		if old.PositionType != t.PositionType || !equalPosition(old.Position, t.Position) {
+			trains = new(Int)
+			continue
+		}
+		trains = first(trains, trainPlanChange(old.Plan, t.Plan))
+	}
+	all = first(nil, trains)
+	for k, pa := range w.Passengers {
+		all = first(all, passengerPlanChange(p.passengers[k], pa.Plan))
+	}
+	if all == nil {
+		all = new(Int)
+	}
+	return all, trains
+}
+
+// trainPlanChange returns the time of the first difference of the plans a and b, or nil.
+func trainPlanChange(a, b []TrainAction) *Int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		switch {
+		case i == len(a):
+			return &b[i].Time
+		case i == len(b):
+			return &a[i].Time
+		case a[i].Time.Cmp(&b[i].Time) == -1:
+			return &a[i].Time
+		case a[i].Time.Cmp(&b[i].Time) == +1:
+			return &b[i].Time
+		case a[i].Type != b[i].Type || a[i].Target != b[i].Target:
+			return &a[i].Time
+		}
+	}
+	return nil
+}
+
+// passengerPlanChange returns the time of the first difference of the plans a and b, or nil.
+func passengerPlanChange(a, b []PassengerAction) *Int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		switch {
+		case i == len(a):
+			return &b[i].Time
+		case i == len(b):
+			return &a[i].Time
+		case a[i].Time.Cmp(&b[i].Time) == -1:
+			return &a[i].Time
+		case a[i].Time.Cmp(&b[i].Time) == +1:
+			return &b[i].Time
+		case a[i].Type != b[i].Type || a[i].Train != b[i].Train:
+			return &a[i].Time
+		}
+	}
+	return nil
+}
+
+func equalPosition(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// repairer fixes a single violation.
+type repairer struct {
+	// w is the world with the plan which is changed, it is not simulated
+	w *World
+	// sim is the world after the simulation stopped at the violation
+	sim *World
+	// events contains the movements of all trains sorted by time
+	events []Event
+}
+
+// stop is a stay of a train at a station. Trains are at the station for actions in [arrive, depart]
+// and passengers can board or detrain in [arrive+1, depart-1].
+type stop struct {
+	station string
+	arrive  Int
+	// depart is nil if the train does not leave the station
+	depart *Int
+}
+
+// stops returns all stops of train in order of time.
+func (r *repairer) stops(train string) []stop {
+	var stops []stop
+	if t, ok := r.w.Trains[train]; ok && t.PositionType == TrainPositionStation {
+		stops = append(stops, stop{station: t.Position[0]})
+	}
+	for i := range r.events {
+		e := &r.events[i]
+		if e.Train != train {
+			continue
+		}
+		switch e.Type {
+		case EventTrainDepart:
+			if len(stops) != 0 {
+				stops[len(stops)-1].depart = new(Int).Set(&e.Time)
+			}
+		case EventTrainArrive:
+			stops = append(stops, stop{station: e.Station, arrive: e.Time.clone()})
+		}
+	}
+	return stops
+}
+
+// stopAt returns the stop of train at time t.
+func (r *repairer) stopAt(train string, t *Int) (stop, bool) {
+	for _, s := range r.stops(train) {
+		if s.arrive.Cmp(t) != +1 && (s.depart == nil || s.depart.Cmp(t) != -1) {
+			return s, true
+		}
+	}
+	return stop{}, false
+}
+
+// boardable returns the first time not before from at which passengers can board or leave train at station.
+// Any station is accepted if station is empty.
+func (r *repairer) boardable(train, station string, from *Int) (*Int, bool) {
+	for _, s := range r.stops(train) {
+		if station != "" && s.station != station {
+			continue
+		}
+		first := new(Int).Add(&s.arrive, NewInt(1))
+		if first.Cmp(from) == -1 {
+			first.Set(from)
+		}
+		if s.depart == nil || first.Cmp(s.depart) == -1 {
+			return first, true
+		}
+	}
+	return nil, false
+}
+
+// lastBoardable returns the last time in (after, to] at which passengers can board or leave train at station.
+func (r *repairer) lastBoardable(train, station string, after, to *Int) (*Int, bool) {
+	var last *Int
+	for _, s := range r.stops(train) {
+		if station != "" && s.station != station {
+			continue
+		}
+		first := new(Int).Add(&s.arrive, NewInt(1))
+		if first.Cmp(after) != +1 {
+			first.Add(after, NewInt(1))
+		}
+		end := new(Int).Set(to)
+		if s.depart != nil && s.depart.Cmp(end) != +1 {
+			end.Sub(s.depart, NewInt(1))
+		}
+		if first.Cmp(end) != +1 {
+			last = end
+		}
+	}
+	return last, last != nil
+}
+
+// nearestBoardable returns the time closest to now at which passengers can board or leave train at station.
+// Only times later than after are considered, earlier times are preferred on ties.
+func (r *repairer) nearestBoardable(train, station string, after, now *Int) (*Int, bool) {
+	earlier, ok := r.lastBoardable(train, station, after, now)
+	later, okLater := r.boardable(train, station, now)
+	if !ok || !okLater {
+		if ok {
+			return earlier, true
+		}
+		return later, okLater
+	}
+	if new(Int).Sub(now, earlier).Cmp(new(Int).Sub(later, now)) != +1 {
+		return earlier, true
+	}
+	return later, true
+}
+
+// fix changes the plan of r.w so that v does not happen any more. It returns a description of the change.
+func (r *repairer) fix(v *SimulationError) (string, bool) {
+	switch v.Entity {
+	case EntityTrain:
+		t, ok := r.w.Trains[v.ID]
+		if !ok {
+			return "", false
+		}
+		if i, ok := trainActionAt(t, &v.Time); ok {
+			return r.fixTrainAction(t, i, &v.Time)
+		}
+		return r.fixTrainCapacity(t, &v.Time)
+	case EntityPassenger:
+		p, ok := r.w.Passengers[v.ID]
+		if !ok {
+			return "", false
+		}
+		if i, ok := passengerActionAt(p, &v.Time); ok {
+			return r.fixPassengerAction(p, i, &v.Time)
+		}
+		return r.fixTarget(p)
+	case EntityStation:
+		return r.fixCapacity(EventTrainArrive, EventTrainDepart, func(e *Event) bool { return e.Station == v.ID }, &v.Time)
+	case EntityLine:
+		return r.fixCapacity(EventTrainDepart, EventTrainArrive, func(e *Event) bool { return e.Line == v.ID }, &v.Time)
+	}
+	return "", false
+}
+
+// fixTrainAction fixes action i of t at time now, which is not possible at the position of the train.
+func (r *repairer) fixTrainAction(t *Train, i int, now *Int) (string, bool) {
+	action := t.Plan[i].String()
+	if _, ok := r.stopAt(t.ID, now); ok {
+		// At a station, so the line does not fit
+		dropTrainAction(t, i)
+		return fmt.Sprintf("drop '%s' of train %s", action, t.ID), true
+	}
+
+	if t.PositionType == TrainPositionWildcard {
+		line, ok := r.w.Lines[t.Plan[i].Target]
+		if !ok {
+			dropTrainAction(t, i)
+			return fmt.Sprintf("drop '%s' of train %s", action, t.ID), true
+		}
+		for _, k := range line.End {
+			st, ok := r.w.Stations[k]
+			if !ok || st.CurrenTrains.Cmp(&st.Capacity) != -1 {
+				continue
+			}
+			t.Position = []string{st.ID}
+			t.PositionType = TrainPositionStation
+			st.CurrenTrains.Add(&st.CurrenTrains, NewInt(1))
+			return fmt.Sprintf("add '0 Start %s' to train %s", st.ID, t.ID), true
+		}
+		return "", false
+	}
+
+	// Still on a line, wait for the arrival
+	for _, s := range r.stops(t.ID) {
+		if s.arrive.Cmp(now) == +1 {
+			delayTrainAction(t, i, &s.arrive)
+			return fmt.Sprintf("delay '%s' of train %s to %s", action, t.ID, s.arrive.String()), true
+		}
+	}
+	return "", false
+}
+
+// fixTrainCapacity moves the last passenger (in natural order) boarding t at time now to a later stop of a train
+// at the same station, see reboard.
+func (r *repairer) fixTrainCapacity(t *Train, now *Int) (string, bool) {
+	var boarding *Passenger
+	for _, p := range r.sim.Passengers {
+		if p.PositionType != PassengerPositionTrain || p.Position != t.ID || len(p.Legs) == 0 {
+			continue
+		}
+		if p.Legs[len(p.Legs)-1].Board.Cmp(now) != 0 {
+			continue
+		}
+		if boarding == nil || CompareIDs(p.ID, boarding.ID) > 0 {
+			boarding = p
+		}
+	}
+	if boarding == nil {
+		return "", false
+	}
+	p := r.w.Passengers[boarding.ID]
+	i, ok := passengerActionAt(p, now)
+	if !ok {
+		return "", false
+	}
+	s, ok := r.stopAt(t.ID, now)
+	if !ok || s.depart == nil {
+		return r.dropLeg(p, i), true
+	}
+	return r.reboard(p, i, s.station, s.depart, new(Int).Add(s.depart, NewInt(1))), true
+}
+
+// fixPassengerAction fixes action i of p at time now, which is not possible at the position of the passenger.
+func (r *repairer) fixPassengerAction(p *Passenger, i int, now *Int) (string, bool) {
+	sp := r.sim.Passengers[p.ID]
+	a := &p.Plan[i]
+	switch a.Type {
+	case PassengerActionBoard:
+		if sp.PositionType == PassengerPositionTrain && i > 0 && p.Plan[i-1].Type == PassengerActionBoard {
+			// The detraining of the current train is missing
+			t, ok := r.nearestBoardable(sp.Position, r.detrainStation(p, i), previousAction(p, i), now)
+			if !ok {
+				return r.dropLeg(p, i), true
+			}
+			action := a.String()
+			a.Type, a.Train = PassengerActionDetrain, ""
+			movePassengerAction(p, i, t)
+			return fmt.Sprintf("replace '%s' of passenger %s by '%s'", action, p.ID, p.Plan[i].String()), true
+		}
+		if _, ok := r.w.Trains[a.Train]; !ok || sp.PositionType != PassengerPositionStation {
+			return r.dropLeg(p, i), true
+		}
+		return r.reboard(p, i, sp.Position, previousAction(p, i), now), true
+	case PassengerActionDetrain:
+		if sp.PositionType != PassengerPositionTrain {
+			action := a.String()
+			dropPassengerAction(p, i)
+			return fmt.Sprintf("drop '%s' of passenger %s", action, p.ID), true
+		}
+		t, ok := r.nearestBoardable(sp.Position, r.detrainStation(p, i), previousAction(p, i), now)
+		if !ok {
+			t, ok = r.nearestBoardable(sp.Position, "", previousAction(p, i), now)
+		}
+		if !ok {
+			return "", false
+		}
+		action := a.String()
+		movePassengerAction(p, i, t)
+		return fmt.Sprintf("move '%s' of passenger %s to '%s'", action, p.ID, p.Plan[i].String()), true
+	}
+	return "", false
+}
+
+// fixTarget lets p detrain at the target if the passenger did not reach it.
+func (r *repairer) fixTarget(p *Passenger) (string, bool) {
+	sp := r.sim.Passengers[p.ID]
+	if len(sp.Legs) == 0 || len(p.Plan) == 0 {
+		return "", false
+	}
+	leg := &sp.Legs[len(sp.Legs)-1]
+	t, ok := r.boardable(leg.Train, p.Target, new(Int).Add(&leg.Board, NewInt(1)))
+	if !ok {
+		return "", false
+	}
+
+	last := len(p.Plan) - 1
+	if sp.PositionType == PassengerPositionTrain {
+		action := PassengerAction{Time: *t, Type: PassengerActionDetrain}
+		if p.Plan[last].Time.Cmp(t) != -1 || !p.AddAction(action) {
+			return "", false
+		}
+		return fmt.Sprintf("add '%s' to passenger %s", action.String(), p.ID), true
+	}
+	if p.Plan[last].Type != PassengerActionDetrain || (last > 0 && p.Plan[last-1].Time.Cmp(t) != -1) {
+		return "", false
+	}
+	action := p.Plan[last].String()
+	p.Plan[last].Time.Set(t)
+	return fmt.Sprintf("move '%s' of passenger %s to '%s'", action, p.ID, p.Plan[last].String()), true
+}
+
+// fixCapacity delays the departure of the last train (in natural order) causing an event of type cause at time now
+// until another train causes an event of type release afterwards. Only events for which match returns true are considered.
+// The departure is dropped if no train releases the capacity.
+func (r *repairer) fixCapacity(cause, release EventType, match func(e *Event) bool, now *Int) (string, bool) {
+	train := ""
+	for i := range r.events {
+		e := &r.events[i]
+		if e.Type == cause && e.Time.Cmp(now) == 0 && match(e) && (train == "" || CompareIDs(e.Train, train) > 0) {
+			train = e.Train
+		}
+	}
+	t, ok := r.w.Trains[train]
+	if !ok {
+		return "", false
+	}
+	var next *Int
+	for i := range r.events {
+		e := &r.events[i]
+		if e.Type == release && e.Train != train && e.Time.Cmp(now) == +1 && match(e) {
+			next = new(Int).Set(&e.Time)
+			break
+		}
+	}
+
+	// Find the departure of the train which caused the event
+	var depart *Int
+	for i := range r.events {
+		e := &r.events[i]
+		if e.Train == train && e.Type == EventTrainDepart && e.Time.Cmp(now) != +1 {
+			depart = &e.Time
+		}
+	}
+	if depart == nil {
+		return "", false
+	}
+	i, ok := trainActionAt(t, depart)
+	if !ok {
+		return "", false
+	}
+	action := t.Plan[i].String()
+	if next == nil {
+		dropTrainAction(t, i)
+		return fmt.Sprintf("drop '%s' of train %s", action, t.ID), true
+	}
+	delayed := new(Int).Sub(next, now)
+	delayed.Add(delayed, depart)
+	delayTrainAction(t, i, delayed)
+	return fmt.Sprintf("delay '%s' of train %s to %s", action, t.ID, delayed.String()), true
+}
+
+// reboard moves the boarding at index i of p at station to the time closest to now, only times later than after
+// are considered. The train has to reach the destination of the leg afterwards, which is the station needed by
+// the following detraining (see detrainStation) or the target. The following detraining is moved to the first stop
+// of the train at the destination.
+//
+// The planned train is preferred, other trains (in natural order) are only used if it does not reach
+// the destination. The leg is dropped if no train can be used.
+func (r *repairer) reboard(p *Passenger, i int, station string, after, now *Int) string {
+	dest := p.Target
+	detrain := i+1 < len(p.Plan) && p.Plan[i+1].Type == PassengerActionDetrain
+	if detrain {
+		if s := r.detrainStation(p, i+1); s != "" {
+			dest = s
+		}
+	}
+
+	train := p.Plan[i].Train
+	board, arrive, ok := r.boarding(train, station, dest, after, now)
+	if !ok {
+		ids := make([]string, 0, len(r.w.Trains))
+		for k := range r.w.Trains {
+			ids = append(ids, k)
+		}
+		SortIDs(ids)
+		for _, k := range ids {
+			b, a, found := r.boarding(k, station, dest, after, now)
+			if !found {
+				continue
+			}
+			if !ok || new(Int).Sub(b, now).Big().CmpAbs(new(Int).Sub(board, now).Big()) == -1 {
+				train, board, arrive, ok = k, b, a, true
+			}
+		}
+	}
+	if !ok {
+		return r.dropLeg(p, i)
+	}
+
+	action := p.Plan[i].String()
+	p.Plan[i].Train = train
+	movePassengerAction(p, i, board)
+	if !detrain || p.Plan[i+1].Time.Cmp(arrive) == 0 {
+		return fmt.Sprintf("move '%s' of passenger %s to '%s'", action, p.ID, p.Plan[i].String())
+	}
+	detrainAction := p.Plan[i+1].String()
+	movePassengerAction(p, i+1, arrive)
+	return fmt.Sprintf("move '%s' and '%s' of passenger %s to '%s' and '%s'", action, detrainAction, p.ID, p.Plan[i].String(), p.Plan[i+1].String())
+}
+
+// boarding returns the time closest to now at which passengers can board train at station and the first time
+// afterwards at which they can leave it at dest. Only boarding times later than after are considered,
+// earlier times are preferred on ties.
+func (r *repairer) boarding(train, station, dest string, after, now *Int) (*Int, *Int, bool) {
+	var board, arrive *Int
+	for _, s := range r.stops(train) {
+		if s.station != station {
+			continue
+		}
+		t := new(Int).Add(&s.arrive, NewInt(1))
+		if t.Cmp(after) != +1 {
+			t.Add(after, NewInt(1))
+		}
+		if t.Cmp(now) == -1 {
+			t.Set(now)
+		}
+		if s.depart != nil && t.Cmp(s.depart) != -1 {
+			// Closest time in the stop is its last timestep
+			t.Sub(s.depart, NewInt(1))
+			if t.Cmp(&s.arrive) != +1 || t.Cmp(after) != +1 {
+				continue
+			}
+		}
+		a, ok := r.boardable(train, dest, new(Int).Add(t, NewInt(1)))
+		if !ok {
+			continue
+		}
+		if board == nil || new(Int).Sub(t, now).Big().CmpAbs(new(Int).Sub(board, now).Big()) == -1 {
+			board, arrive = t, a
+		}
+	}
+	return board, arrive, board != nil
+}
+
+// dropLeg removes the boarding at index i of p together with a following detraining.
+func (r *repairer) dropLeg(p *Passenger, i int) string {
+	action := p.Plan[i].String()
+	if i+1 < len(p.Plan) && p.Plan[i+1].Type == PassengerActionDetrain {
+		detrain := p.Plan[i+1].String()
+		dropPassengerAction(p, i+1)
+		dropPassengerAction(p, i)
+		return fmt.Sprintf("drop '%s' and '%s' of passenger %s", action, detrain, p.ID)
+	}
+	dropPassengerAction(p, i)
+	return fmt.Sprintf("drop '%s' of passenger %s", action, p.ID)
+}
+
+// detrainStation returns the station at which the detraining at index i of p is needed.
+// This is the target for the last action and the station of the train boarded next otherwise.
+// An empty string is returned if the station is not known.
+func (r *repairer) detrainStation(p *Passenger, i int) string {
+	if i == len(p.Plan)-1 {
+		return p.Target
+	}
+	next := &p.Plan[i+1]
+	if next.Type != PassengerActionBoard {
+		return ""
+	}
+	s, ok := r.stopAt(next.Train, &next.Time)
+	if !ok {
+		return ""
+	}
+	return s.station
+}
+
+// trainActionAt returns the index of the action of t at time now.
+func trainActionAt(t *Train, now *Int) (int, bool) {
+	i := sort.Search(len(t.Plan), func(i int) bool { return t.Plan[i].Time.Cmp(now) != -1 })
+	return i, i < len(t.Plan) && t.Plan[i].Time.Cmp(now) == 0
+}
+
+// passengerActionAt returns the index of the action of p at time now.
+func passengerActionAt(p *Passenger, now *Int) (int, bool) {
+	i := sort.Search(len(p.Plan), func(i int) bool { return p.Plan[i].Time.Cmp(now) != -1 })
+	return i, i < len(p.Plan) && p.Plan[i].Time.Cmp(now) == 0
+}
+
+// delayTrainAction moves action i of t to time to, which must not be earlier.
+// Following actions are delayed to keep the plan in order.
+func delayTrainAction(t *Train, i int, to *Int) {
+	t.Plan[i].Time.Set(to)
+	for j := i + 1; j < len(t.Plan) && t.Plan[j].Time.Cmp(&t.Plan[j-1].Time) != +1; j++ {
+		t.Plan[j].Time.Add(&t.Plan[j-1].Time, NewInt(1))
+	}
+}
+
+// movePassengerAction moves action i of p to time to, which must be later than the previous action.
+// Following actions are delayed to keep the plan in order.
+func movePassengerAction(p *Passenger, i int, to *Int) {
+	p.Plan[i].Time.Set(to)
+	for j := i + 1; j < len(p.Plan) && p.Plan[j].Time.Cmp(&p.Plan[j-1].Time) != +1; j++ {
+		p.Plan[j].Time.Add(&p.Plan[j-1].Time, NewInt(1))
+	}
+}
+
+// previousAction returns the time of the action of p before index i, or 0.
+func previousAction(p *Passenger, i int) *Int {
+	if i == 0 {
+		return new(Int)
+	}
+	return &p.Plan[i-1].Time
+}
+
+func dropTrainAction(t *Train, i int) {
+	t.Plan = append(t.Plan[:i], t.Plan[i+1:]...)
+}
+
+func dropPassengerAction(p *Passenger, i int) {
+	p.Plan = append(p.Plan[:i], p.Plan[i+1:]...)
+}
@@ -0,0 +1,172 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+	network := "[Stations]\nS1 1\nS2 2\n\n[Lines]\nL1 S1 S2 3 1\n\n"
+	tests := []struct {
+		name       string
+		trains     string
+		passengers string
+		plan       string
+		fix        string
+	}{
+		{"board late", "T1 S2 1 10", "P1 S1 S2 5 10",
+			"[Train:T1]\n1 Depart L1\n5 Depart L1\n\n[Passenger:P1]\n3 Board T1\n8 Detrain\n",
+			"move '3 Board T1' of passenger P1 to '4 Board T1'"},
+		{"board after departure", "T1 S2 1 10", "P1 S1 S2 5 10",
+			"[Train:T1]\n1 Depart L1\n5 Depart L1\n\n[Passenger:P1]\n5 Board T1\n8 Detrain\n",
+			"move '5 Board T1' of passenger P1 to '4 Board T1'"},
+		{"board other train", "T1 S2 1 10\nT2 S2 1 10", "P1 S1 S2 5 10",
+			"[Train:T1]\n1 Depart L1\n5 Depart L1\n\n[Passenger:P1]\n3 Board T2\n8 Detrain\n",
+			"move '3 Board T2' of passenger P1 to '4 Board T1'"},
+		{"board and detrain late", "T1 S2 1 10", "P1 S1 S2 5 10",
+			"[Train:T1]\n1 Depart L1\n5 Depart L1\n\n[Passenger:P1]\n6 Board T1\n10 Detrain\n",
+			"move '6 Board T1' and '10 Detrain' of passenger P1 to '4 Board T1' and '8 Detrain'"},
+		{"detrain on line", "T1 S2 1 10", "P1 S1 S2 5 10",
+			"[Train:T1]\n1 Depart L1\n5 Depart L1\n\n[Passenger:P1]\n4 Board T1\n7 Detrain\n",
+			"move '7 Detrain' of passenger P1 to '8 Detrain'"},
+		{"detrain at wrong station", "T1 S1 1 10", "P1 S1 S2 5 10",
+			"[Train:T1]\n2 Depart L1\n6 Depart L1\n\n[Passenger:P1]\n1 Board T1\n9 Detrain\n",
+			"move '9 Detrain' of passenger P1 to '5 Detrain'"},
+		{"board without detrain", "T1 S2 1 10", "P1 S1 S2 5 10",
+			"[Train:T1]\n1 Depart L1\n5 Depart L1\n\n[Passenger:P1]\n4 Board T1\n8 Board T1\n",
+			"replace '8 Board T1' of passenger P1 by '8 Detrain'"},
+		{"depart on line", "T1 S2 1 10", "",
+			"[Train:T1]\n1 Depart L1\n2 Depart L1\n",
+			"delay '2 Depart L1' of train T1 to 3"},
+		{"station capacity", "T1 S1 1 10\nT2 S2 1 10", "",
+			"[Train:T1]\n4 Depart L1\n\n[Train:T2]\n1 Depart L1\n",
+			"delay '1 Depart L1' of train T2 to 2"},
+		{"no start", "T1 * 1 10", "",
+			"[Train:T1]\n1 Depart L1\n",
+			"add '0 Start S1' to train T1"},
+	}
+
+	for _, test := range tests {
+		input := []byte(network + "[Trains]\n" + test.trains + "\n\n[Passengers]\n" + test.passengers + "\n")
+		plan, changes, err := Repair(input, []byte(test.plan))
+		if err != nil {
+			t.Errorf("%s: can not repair: %v", test.name, err)
+			continue
+		}
+		if len(changes) != 1 || changes[0].Fix != test.fix {
+			t.Errorf("%s: wrong changes: %v", test.name, changes)
+		}
+		w, err := parseRepair(input, plan)
+		if err != nil {
+			t.Errorf("%s: can not parse repaired plan: %v", test.name, err)
+			continue
+		}
+		if r := w.Run(Options{}); !r.Valid {
+			t.Errorf("%s: repaired plan not valid: %v\n%s", test.name, r.Errors, plan)
+		}
+	}
+}
+
+func TestRepairTestCases(t *testing.T) {
+	tests := []struct {
+		dir   string
+		valid bool
+	}{
+		{"simple", true},
+		{"stationCapacityExceeded", true},
+		{"boardingOnLine", true},
+	}
+	for _, test := range tests {
+		input, err := os.ReadFile(path.Join("..", "test", test.dir, "input.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		plan, err := os.ReadFile(path.Join("..", "test", test.dir, "output.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		repaired, changes, err := Repair(input, plan)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: unfixable plan repaired: %v", test.dir, changes)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: can not repair: %v", test.dir, err)
+			continue
+		}
+		w, err := ParseInputReader(bytes.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ParsePlanReader(w, bytes.NewReader(repaired))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := w.Run(Options{}); !r.Valid {
+			t.Errorf("%s: repaired plan not valid: %v", test.dir, r.Errors)
+		}
+		t.Log(test.dir, changes)
+	}
+}
+
+func TestRepairLarge(t *testing.T) {
+	if skipLarge("large") {
+		t.Skip("repairs on test/large only run with -large")
+	}
+	input, err := os.ReadFile(path.Join("..", "test", "large", "input.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := os.ReadFile(path.Join("..", "test", "large", "output.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Line 1744 is '13 Detrain' of passenger P3, who rides T6
+	lines := bytes.Split(plan, []byte("\n"))
+	lines[1743] = []byte("13 Board T1")
+	plan = bytes.Join(lines, []byte("\n"))
+
+	repaired, changes, err := Repair(input, plan)
+	if err != nil {
+		t.Fatalf("can not repair: %v (changes: %v)", err, changes)
+	}
+	w, err := parseRepair(input, repaired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := w.Run(Options{}); !r.Valid {
+		t.Errorf("repaired plan not valid: %v", r.Errors)
+	}
+	t.Log(changes)
+}
+
+func parseRepair(input, plan []byte) (*World, error) {
+	w, err := ParseInputReader(bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+	err = ParsePlanReader(w, bytes.NewReader(plan))
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
@@ -0,0 +1,436 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"container/heap"
+	"sync"
+)
+
+// TravelTime returns the number of timesteps a train with the given speed needs for a line of the given length.
+// It returns false if speed is not positive.
+//
+// The rules of the simulation result in the following timing for a train departing at time t with travel time k:
+//   - The train arrives at the end of the line at time t+k-1.
+//   - The next action of the train (e.g. the next departure) can be at time max(t+1, t+k-1).
+//   - Passengers can board or detrain at time t+k.
+func TravelTime(length, speed *Rat) (*Int, bool) {
+	if speed.Sign() != +1 {
+		return nil, false
+	}
+	k := length.CeilQuo(speed)
+	if k.Cmp(NewInt(1)) == -1 {
+		k.SetInt64(1)
+	}
+	return k, true
+}
+
+// TravelTime returns the number of timesteps t needs for l, see TravelTime.
+func (t *Train) TravelTime(l *Line) (*Int, bool) {
+	return TravelTime(&l.Length, &t.Speed)
+}
+
+// readyTime returns the number of timesteps after a departure until the train can act again at the end of a line
+// with travel time k.
+func readyTime(k *Int) *Int {
+	r := new(Int).Sub(k, NewInt(1))
+	if r.Cmp(NewInt(1)) == -1 {
+		r.SetInt64(1)
+	}
+	return r
+}
+
+// Path is a route through the network.
+type Path struct {
+	// Stations contains all stations of the path including start and end.
+	Stations []string
+	// Lines contains the IDs of the lines in travel order.
+	Lines []string
+	// Length is the sum of the length of all lines.
+	Length Rat
+}
+
+// Ticks returns the number of timesteps a train with the given speed needs for p when departing as early as possible:
+// if the train departs the first line at time t, passengers can detrain at the end at time t+Ticks.
+// It returns false if speed is not positive or p contains unknown lines.
+func (p *Path) Ticks(w *World, speed *Rat) (*Int, bool) {
+	ticks := new(Int)
+	for i := range p.Lines {
+		l, ok := w.Lines[p.Lines[i]]
+		if !ok {
+			return nil, false
+		}
+		k, ok := TravelTime(&l.Length, speed)
+		if !ok {
+			return nil, false
+		}
+		if i == len(p.Lines)-1 {
+			ticks.Add(ticks, k)
+		} else {
+			ticks.Add(ticks, readyTime(k))
+		}
+	}
+	return ticks, true
+}
+
+// Router answers shortest path queries on the network of a world.
+// Results are computed with Dijkstra's algorithm for each source on first use and cached,
+// so the all-pairs results are available by querying all sources.
+// Stations missing in the world are not used for routing.
+// A Router is safe for concurrent use. It has to be recreated if the network changes.
+type Router struct {
+	w *World
+	n *Network
+
+	l sync.Mutex
+	// distances contains the shortest path trees by length per source node
+	distances map[int]*pathTree
+	// ticks contains the shortest path trees by ready time per speed and source node
+	ticks map[string]map[int]*pathTree
+	// weights contains the edge weights for each speed in the order of the adjacency lists, "" denotes the length
+	weights map[string][][]Rat
+}
+
+// NewRouter returns a router for the network of w.
+func NewRouter(w *World) *Router {
+	return &Router{
+		w:         w,
+		n:         w.Network(),
+		distances: make(map[int]*pathTree),
+		ticks:     make(map[string]map[int]*pathTree),
+		weights:   make(map[string][][]Rat),
+	}
+}
+
+// pathTree is the result of a single source shortest path search.
+type pathTree struct {
+	source int
+	cost   []Rat
+	// reached is false for nodes not reachable from source
+	reached []bool
+	// via is the edge used to reach a node, it is only set for reached nodes except source
+	via []Edge
+	// from is the predecessor of a node in the tree
+	from []int
+}
+
+// pathTo returns the path from the source of tree to node, which must be reachable.
+func (r *Router) pathTo(tree *pathTree, node int) Path {
+	var p Path
+	for v := node; v != tree.source; v = tree.from[v] {
+		p.Lines = append(p.Lines, tree.via[v].Line.ID)
+		p.Stations = append(p.Stations, r.n.ids[v])
+		p.Length.Add(&p.Length, &tree.via[v].Line.Length)
+	}
+	p.Stations = append(p.Stations, r.n.ids[tree.source])
+	for i, j := 0, len(p.Lines)-1; i < j; i, j = i+1, j-1 {
+		p.Lines[i], p.Lines[j] = p.Lines[j], p.Lines[i]
+	}
+	for i, j := 0, len(p.Stations)-1; i < j; i, j = i+1, j-1 {
+		p.Stations[i], p.Stations[j] = p.Stations[j], p.Stations[i]
+	}
+	return p
+}
+
+// dijkstra computes the shortest path tree from source with the given edge weights.
+// Paths do not pass nodes for which skip returns true, but these nodes are reached. skip may be nil.
+// Ties are broken by the natural order of stations and lines, so results are deterministic.
+func (r *Router) dijkstra(source int, weights [][]Rat, skip func(node int) bool) *pathTree {
+	nodes := r.n.known
+	tree := &pathTree{
+		source:  source,
+		cost:    make([]Rat, nodes),
+		reached: make([]bool, nodes),
+		via:     make([]Edge, nodes),
+		from:    make([]int, nodes),
+	}
+	done := make([]bool, nodes)
+	tree.reached[source] = true
+	q := &pathQueue{{node: source}}
+	for q.Len() != 0 {
+		item := heap.Pop(q).(pathItem)
+		if done[item.node] {
+			continue
+		}
+		done[item.node] = true
+		if item.node != source && skip != nil && skip(item.node) {
+			continue
+		}
+		var cost Rat
+		for i, e := range r.n.adjacency[item.node] {
+			if e.node >= nodes || done[e.node] {
+				continue
+			}
+			cost.Add(&tree.cost[item.node], &weights[item.node][i])
+			if tree.reached[e.node] && cost.Cmp(&tree.cost[e.node]) != -1 {
+				continue
+			}
+			tree.reached[e.node] = true
+			tree.cost[e.node] = cost
+			tree.via[e.node] = e
+			tree.from[e.node] = item.node
+			heap.Push(q, pathItem{node: e.node, cost: cost})
+		}
+	}
+	return tree
+}
+
+// edgeWeights returns the weights of all edges for speed, or the length of all lines if speed is nil.
+func (r *Router) edgeWeights(speed *Rat) [][]Rat {
+	key := ""
+	if speed != nil {
+		key = speed.String()
+	}
+	r.l.Lock()
+	defer r.l.Unlock()
+	weights, ok := r.weights[key]
+	if ok {
+		return weights
+	}
+	weights = make([][]Rat, len(r.n.adjacency))
+	for i := range r.n.adjacency {
+		weights[i] = make([]Rat, len(r.n.adjacency[i]))
+		for j, e := range r.n.adjacency[i] {
+			if speed == nil {
+				weights[i][j] = e.Line.Length
+				continue
+			}
+			k, _ := TravelTime(&e.Line.Length, speed)
+			weights[i][j].SetInt(readyTime(k))
+		}
+	}
+	r.weights[key] = weights
+	return weights
+}
+
+// distanceTree returns the shortest path tree by length from source.
+func (r *Router) distanceTree(source int) *pathTree {
+	weights := r.edgeWeights(nil)
+	r.l.Lock()
+	defer r.l.Unlock()
+	tree, ok := r.distances[source]
+	if !ok {
+		tree = r.dijkstra(source, weights, nil)
+		r.distances[source] = tree
+	}
+	return tree
+}
+
+// readyTree returns the shortest path tree by ready time of a train with the given speed from source.
+func (r *Router) readyTree(source int, speed *Rat) *pathTree {
+	weights := r.edgeWeights(speed)
+	key := speed.String()
+	r.l.Lock()
+	defer r.l.Unlock()
+	trees, ok := r.ticks[key]
+	if !ok {
+		trees = make(map[int]*pathTree)
+		r.ticks[key] = trees
+	}
+	tree, ok := trees[source]
+	if !ok {
+		tree = r.dijkstra(source, weights, nil)
+		trees[source] = tree
+	}
+	return tree
+}
+
+// station returns the node of a station of the world.
+func (r *Router) station(id string) (int, bool) {
+	i, ok := r.n.index[id]
+	if !ok || i >= r.n.known {
+		return 0, false
+	}
+	return i, true
+}
+
+// ShortestPath returns the shortest path from one station to another by length.
+// It returns false if there is no path.
+func (r *Router) ShortestPath(from, to string) (Path, bool) {
+	a, ok := r.station(from)
+	if !ok {
+		return Path{}, false
+	}
+	b, ok := r.station(to)
+	if !ok {
+		return Path{}, false
+	}
+	tree := r.distanceTree(a)
+	if !tree.reached[b] {
+		return Path{}, false
+	}
+	return r.pathTo(tree, b), true
+}
+
+// FastestPath returns the path from one station to another with the lowest number of timesteps
+// for a train with the given speed, see Path.Ticks.
+// It returns false if there is no path or speed is not positive.
+func (r *Router) FastestPath(from, to string, speed *Rat) (Path, *Int, bool) {
+	return r.fastestPath(from, to, speed, nil)
+}
+
+// FastestPathAvoiding is like FastestPath, but the path does not pass stations for which avoid returns true.
+// Start and end of the path are not checked. Results are not cached.
+func (r *Router) FastestPathAvoiding(from, to string, speed *Rat, avoid func(station string) bool) (Path, *Int, bool) {
+	return r.fastestPath(from, to, speed, func(node int) bool { return avoid(r.n.ids[node]) })
+}
+
+// avoidingTree returns the uncached shortest path tree by ready time from station from, which does not pass nodes
+// for which skip returns true.
+func (r *Router) avoidingTree(from string, speed *Rat, skip func(node int) bool) (*pathTree, bool) {
+	a, ok := r.station(from)
+	if !ok || speed.Sign() != +1 {
+		return nil, false
+	}
+	return r.dijkstra(a, r.edgeWeights(speed), skip), true
+}
+
+// fastestTo returns the fastest path to station to in a tree returned by avoidingTree.
+func (r *Router) fastestTo(tree *pathTree, to string, speed *Rat, skip func(node int) bool) (Path, *Int, bool) {
+	b, ok := r.station(to)
+	if !ok {
+		return Path{}, nil, false
+	}
+	return r.fastestFromTree(tree, b, speed, skip)
+}
+
+func (r *Router) fastestPath(from, to string, speed *Rat, skip func(node int) bool) (Path, *Int, bool) {
+	if speed.Sign() != +1 {
+		return Path{}, nil, false
+	}
+	a, ok := r.station(from)
+	if !ok {
+		return Path{}, nil, false
+	}
+	b, ok := r.station(to)
+	if !ok {
+		return Path{}, nil, false
+	}
+	if a == b {
+		return Path{Stations: []string{from}}, new(Int), true
+	}
+	var tree *pathTree
+	if skip == nil {
+		tree = r.readyTree(a, speed)
+	} else {
+		tree = r.dijkstra(a, r.edgeWeights(speed), skip)
+	}
+	return r.fastestFromTree(tree, b, speed, skip)
+}
+
+// fastestFromTree returns the fastest path to node b from the source of a tree by ready time.
+// skip must be the same as used for the tree.
+func (r *Router) fastestFromTree(tree *pathTree, b int, speed *Rat, skip func(node int) bool) (Path, *Int, bool) {
+	if tree.source == b {
+		return Path{Stations: []string{r.n.ids[b]}}, new(Int), true
+	}
+
+	// The last line counts with its full travel time, so it is chosen separately
+	var best *Int
+	var bestEdge Edge
+	for _, e := range r.n.adjacency[b] {
+		if e.node >= r.n.known || !tree.reached[e.node] {
+			continue
+		}
+		if e.node != tree.source && skip != nil && skip(e.node) {
+			continue
+		}
+		k, _ := TravelTime(&e.Line.Length, speed)
+		ticks := new(Rat).SetInt(k)
+		ticks.Add(ticks, &tree.cost[e.node])
+		total := ticks.CeilQuo(NewRat(1, 1))
+		if best == nil || total.Cmp(best) == -1 {
+			best, bestEdge = total, e
+		}
+	}
+	if best == nil {
+		return Path{}, nil, false
+	}
+	p := r.pathTo(tree, bestEdge.node)
+	p.Lines = append(p.Lines, bestEdge.Line.ID)
+	p.Stations = append(p.Stations, r.n.ids[b])
+	p.Length.Add(&p.Length, &bestEdge.Line.Length)
+	return p, best, true
+}
+
+// TrainPath returns the fastest path for train t, see FastestPath.
+func (r *Router) TrainPath(t *Train, from, to string) (Path, *Int, bool) {
+	return r.FastestPath(from, to, &t.Speed)
+}
+
+// Distances returns the length of the shortest paths from a station to all reachable stations.
+func (r *Router) Distances(from string) map[string]*Rat {
+	a, ok := r.station(from)
+	if !ok {
+		return nil
+	}
+	tree := r.distanceTree(a)
+	result := make(map[string]*Rat)
+	for i := range tree.reached {
+		if tree.reached[i] {
+			result[r.n.ids[i]] = new(Rat).Set(&tree.cost[i])
+		}
+	}
+	return result
+}
+
+// AllPairsTicks returns the number of timesteps of the fastest paths between all pairs of connected stations
+// for a train with the given speed, see FastestPath.
+func (r *Router) AllPairsTicks(speed *Rat) map[string]map[string]*Int {
+	result := make(map[string]map[string]*Int, r.n.known)
+	for _, from := range r.n.ids[:r.n.known] {
+		result[from] = make(map[string]*Int)
+		for _, to := range r.n.ids[:r.n.known] {
+			if _, ticks, ok := r.FastestPath(from, to, speed); ok {
+				result[from][to] = ticks
+			}
+		}
+	}
+	return result
+}
+
+// AllPairsDistances returns the length of the shortest paths between all pairs of connected stations.
+func (r *Router) AllPairsDistances() map[string]map[string]*Rat {
+	result := make(map[string]map[string]*Rat, r.n.known)
+	for _, from := range r.n.ids[:r.n.known] {
+		result[from] = r.Distances(from)
+	}
+	return result
+}
+
+type pathItem struct {
+	node int
+	cost Rat
+}
+
+// pathQueue is a priority queue for Dijkstra's algorithm. Ties are broken by node to stay deterministic.
+type pathQueue []pathItem
+
+func (q pathQueue) Len() int { return len(q) }
+func (q pathQueue) Less(i, j int) bool {
+	if c := q[i].cost.Cmp(&q[j].cost); c != 0 {
+		return c < 0
+	}
+	return q[i].node < q[j].node
+}
+func (q pathQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathItem)) }
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// simulatePath lets a passenger travel path with a single train departing as early as possible from time 2.
+// The passenger detrains at the given time. It returns whether the plan is valid.
+func simulatePath(t *testing.T, network string, p Path, speed string, detrain *Int) bool {
+	t.Helper()
+	input := fmt.Sprintf("%s\n[Trains]\nTX %s %s 1\n[Passengers]\nPX %s %s 1 1\n", network, p.Stations[0], speed, p.Stations[0], p.Stations[len(p.Stations)-1])
+	w, err := ParseInputReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plan strings.Builder
+	plan.WriteString("[Train:TX]\n")
+	depart := NewInt(2)
+	for i := range p.Lines {
+		fmt.Fprintf(&plan, "%s Depart %s\n", depart.String(), p.Lines[i])
+		k, _ := w.Trains["TX"].TravelTime(w.Lines[p.Lines[i]])
+		depart.Add(depart, readyTime(k))
+	}
+	fmt.Fprintf(&plan, "[Passenger:PX]\n1 Board TX\n%s Detrain\n", detrain.String())
+	err = ParsePlanReader(w, strings.NewReader(plan.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w.Run(Options{}).Valid
+}
+
+func TestTravelTimeMatchesSimulation(t *testing.T) {
+	for _, tc := range []struct{ length, speed string }{
+		{"3.14", "0.9999999"},
+		{"4", "5.5"},
+		{"1.5", "0.75"},
+		{"2", "1"},
+		{"10", "3"},
+		{"1", "1"},
+	} {
+		network := fmt.Sprintf("[Stations]\nS1 1\nS2 1\n[Lines]\nL1 S1 S2 %s 1\n", tc.length)
+		p := Path{Stations: []string{"S1", "S2"}, Lines: []string{"L1"}}
+		length, _ := new(Rat).SetString(tc.length)
+		speed, _ := new(Rat).SetString(tc.speed)
+		k, ok := TravelTime(length, speed)
+		if !ok {
+			t.Fatal("can not calculate travel time")
+		}
+		arrival := new(Int).Add(k, NewInt(2))
+		if !simulatePath(t, network, p, tc.speed, arrival) {
+			t.Errorf("%s/%s: detrain at %s not possible", tc.length, tc.speed, arrival)
+		}
+		arrival.Sub(arrival, NewInt(1))
+		if simulatePath(t, network, p, tc.speed, arrival) {
+			t.Errorf("%s/%s: detrain at %s possible", tc.length, tc.speed, arrival)
+		}
+	}
+}
+
+func TestRouter(t *testing.T) {
+	input := "[Stations]\nS1 1\nS2 1\nS3 1\nS4 1\nS5 1\n[Lines]\nL1 S1 S2 1.9 1\nL2 S1 S3 1 1\nL3 S3 S2 1 1\nL4 S2 S4 4 1\n"
+	w, err := ParseInputReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRouter(w)
+
+	p, ok := r.ShortestPath("S1", "S4")
+	if !ok || strings.Join(p.Lines, ",") != "L1,L4" || strings.Join(p.Stations, ",") != "S1,S2,S4" || p.Length.String() != "5.9" {
+		t.Errorf("wrong shortest path: %+v", p)
+	}
+	if _, ok := r.ShortestPath("S1", "S5"); ok {
+		t.Error("S5 is not reachable")
+	}
+
+	// With speed 0.6, L1 needs 4 timesteps while L2 and L3 need 2 each.
+	// Going over S3, the train can depart again when arriving at S3, so passengers can detrain after 1+2 timesteps.
+	speed, _ := new(Rat).SetString("0.6")
+	p, ticks, ok := r.FastestPath("S1", "S2", speed)
+	if !ok || strings.Join(p.Lines, ",") != "L2,L3" || ticks.String() != "3" {
+		t.Errorf("wrong fastest path: %+v %s", p, ticks)
+	}
+	p, ticks, ok = r.FastestPath("S1", "S2", NewRat(2, 1))
+	if !ok || strings.Join(p.Lines, ",") != "L1" || ticks.String() != "1" {
+		t.Errorf("wrong fastest path: %+v %s", p, ticks)
+	}
+
+	all := r.AllPairsTicks(speed)
+	if all["S4"]["S1"].String() != "9" || all["S1"]["S1"].String() != "0" || all["S5"]["S1"] != nil {
+		t.Errorf("wrong all pairs ticks: %v", all["S4"])
+	}
+	distances := r.AllPairsDistances()
+	if distances["S3"]["S4"].String() != "5" {
+		t.Errorf("wrong distance: %s", distances["S3"]["S4"])
+	}
+}
+
+func TestFastestPathMatchesSimulation(t *testing.T) {
+	if skipLarge("large") {
+		t.Skip("simulations on test/large only run with -large")
+	}
+	input, err := os.ReadFile(path.Join("..", "test", "large", "input.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only use stations and lines
+	network := string(input[:strings.Index(string(input), "[Trains]")])
+	w, err := ParseInputReader(strings.NewReader(network))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRouter(w)
+	stations := w.Network().Stations()
+
+	for i, speed := range []string{"1", "0.37", "2.5", "13"} {
+		s, _ := new(Rat).SetString(speed)
+		for j := 0; j < 3; j++ {
+			from := stations[(i*31+j*17)%len(stations)]
+			to := stations[(i*7+j*53+11)%len(stations)]
+			if from == to {
+				continue
+			}
+			p, ticks, ok := r.FastestPath(from, to, s)
+			if !ok {
+				t.Fatalf("no path from %s to %s", from, to)
+			}
+			detrain := new(Int).Add(ticks, NewInt(2))
+			if !simulatePath(t, network, p, speed, detrain) {
+				t.Errorf("%s -> %s with speed %s: detrain at %s not possible", from, to, speed, detrain)
+			}
+			detrain.Sub(detrain, NewInt(1))
+			if simulatePath(t, network, p, speed, detrain) {
+				t.Errorf("%s -> %s with speed %s: detrain at %s possible", from, to, speed, detrain)
+			}
+		}
+	}
+}
@@ -0,0 +1,399 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package simulator implements the rules of the informatiCup 2022 railway network.
+// It parses input and plan files into a World and simulates the plan to calculate the total delay.
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Result contains the outcome of a simulation.
+// If Valid is false, Errors contains the reasons why the plan was rejected and Delay is -1.
+// EndTime is the last simulated timestep (the timestep of the first error if the simulation was aborted).
+type Result struct {
+	Valid      bool
+	Delay      *Int
+	Errors     []error
+	EndTime    *Int
+	Passengers []PassengerResult
+}
+
+// PassengerResult contains the outcome of a simulation for a single passenger group.
+type PassengerResult struct {
+	ID         string
+	Start      string
+	Target     string
+	Size       *Int
+	TargetTime *Int
+	// Arrival is nil if the passenger did not reach the target.
+	Arrival *Int
+	// Delay is the delay multiplied by the group size as returned by Passenger.Delay.
+	Delay *Int
+	// Legs contains all trains the passenger rode in order.
+	Legs []Leg
+}
+
+// Waits returns the time spent waiting at a station before each leg.
+// Passengers are at their start station from time 0.
+func (p PassengerResult) Waits() []*Int {
+	waits := make([]*Int, len(p.Legs))
+	for i := range p.Legs {
+		waits[i] = new(Int).Set(&p.Legs[i].Board)
+		if i > 0 {
+			waits[i].Sub(waits[i], &p.Legs[i-1].Detrain)
+		}
+	}
+	return waits
+}
+
+// Options control how a simulation is run.
+type Options struct {
+	// Verbose receives progress messages if not nil.
+	Verbose io.Writer
+
+	// Trace receives all events of the simulation in a deterministic order if not nil.
+	// Events of a timestep are passed after all updates of the timestep are done.
+	Trace func(Event)
+
+	// TickByTick simulates every single timestep instead of only the timesteps in which something happens.
+	// Both produce identical results; the tick by tick simulation is kept as a reference.
+	TickByTick bool
+
+	// KeepGoing continues the simulation after rule violations so that all violations are reported at once.
+	// Actions violating a rule are skipped. Violations found by validation (e.g. exceeded capacities)
+	// are reported once when they start and not again until the entity became valid in between.
+	KeepGoing bool
+
+	// Resume continues the simulation from a checkpoint instead of starting at time 0 if not nil.
+	Resume *Checkpoint
+
+	// Checkpoint receives a checkpoint after the first simulated timestep not before CheckpointAt if not nil.
+	Checkpoint   func(*Checkpoint)
+	CheckpointAt Int
+}
+
+// Simulate reads the plan at planPath into w and runs the simulation like SimulateReader.
+func Simulate(w *World, planPath string) (Result, error) {
+	f, err := os.Open(planPath)
+	if err != nil {
+		return invalidResult(nil), err
+	}
+	defer f.Close()
+	r, err := SimulateReader(w, f)
+	var perr ParseErrors
+	if errors.As(err, &perr) {
+		perr.setFile(planPath)
+	}
+	return r, err
+}
+
+// SimulateReader reads a plan in the format of the output file from r into w and runs the simulation.
+// An error is only returned if the plan can not be read; rule violations are reported in the Result.
+func SimulateReader(w *World, r io.Reader) (Result, error) {
+	err := ParsePlanReader(w, r)
+	if err != nil {
+		return invalidResult(nil), err
+	}
+	return w.Run(Options{}), nil
+}
+
+// Run simulates the plan already stored in w.
+// w is modified during the simulation and can not be run a second time.
+// All errors in the Result are of type *SimulationError and sorted by SortErrors.
+func (w *World) Run(opt Options) Result {
+	var s *Simulation
+	if opt.Resume != nil {
+		var err error
+		s, err = w.ResumeSimulation(opt, opt.Resume)
+		if err != nil {
+			return w.finish(invalidResult([]error{newSimulationError(w, EntityWorld, "", fmt.Errorf("can not resume: %w", err))}), nil)
+		}
+	} else {
+		s = w.NewSimulation(opt)
+	}
+	checkpoint := opt.Checkpoint != nil
+	for s.Step() {
+		if checkpoint && w.CurrentTime.Cmp(&opt.CheckpointAt) != -1 {
+			opt.Checkpoint(s.Checkpoint())
+			checkpoint = false
+		}
+	}
+	if checkpoint {
+		// The simulation ended before
+		opt.Checkpoint(s.Checkpoint())
+	}
+	return s.Result()
+}
+
+// Simulation is a simulation of a world which is advanced one timestep at a time.
+// Run is the same as stepping a Simulation until it is done.
+type Simulation struct {
+	w   *World
+	opt Options
+	s   *schedule
+
+	errs []error
+	// violating contains all entities which failed their last validation.
+	// It is used in KeepGoing mode to report violations only once.
+	violating map[string]bool
+
+	done   bool
+	result Result
+}
+
+// NewSimulation prepares the simulation of the plan already stored in w and validates the start.
+// w is modified during the simulation and can not be simulated a second time.
+func (w *World) NewSimulation(opt Options) *Simulation {
+	s := &Simulation{w: w, opt: opt, violating: make(map[string]bool)}
+
+	if opt.Trace != nil {
+		w.recorder = new(eventRecorder)
+		trainIDs := make([]string, 0, len(w.Trains))
+		for k := range w.Trains {
+			trainIDs = append(trainIDs, k)
+		}
+		SortIDs(trainIDs)
+		for _, k := range trainIDs {
+			t := w.Trains[k]
+			if t.Wildcard && t.PositionType == TrainPositionStation {
+				st, ok := w.Stations[t.Position[0]]
+				if !ok {
+					continue
+				}
+				w.record(EntityTrain, t.ID, Event{Type: EventTrainStart, Train: t.ID, Station: st.ID})
+				w.recordStationCapacity(EntityTrain, t.ID, st, +1)
+			}
+		}
+		w.flushEvents(opt.Trace)
+	}
+
+	// Build the network before it is used by concurrent train updates
+	w.Network()
+
+	s.verbose("Validating word begin")
+	s.errs = append(s.errs, s.validationErrors(nil, w.ValidateStart())...)
+	if s.errs != nil && !opt.KeepGoing {
+		s.finish(invalidResult(s.errs))
+		return s
+	}
+
+	s.s = newSchedule(w, opt.TickByTick)
+	return s
+}
+
+// World returns the simulated world.
+func (s *Simulation) World() *World {
+	return s.w
+}
+
+// Done returns whether the simulation is finished.
+func (s *Simulation) Done() bool {
+	return s.done
+}
+
+// Errors returns all errors found so far.
+func (s *Simulation) Errors() []error {
+	return s.errs
+}
+
+// Result returns the result of the simulation. It must only be called after the simulation is done.
+func (s *Simulation) Result() Result {
+	return s.result
+}
+
+func (s *Simulation) verbose(a ...interface{}) {
+	if s.opt.Verbose != nil {
+		fmt.Fprintln(s.opt.Verbose, a...)
+	}
+}
+
+// validationErrors returns the errors which are reported in the current timestep.
+// checked contains the validated entities, all other entities keep their state in KeepGoing mode.
+func (s *Simulation) validationErrors(checked []entityRef, found []error) []error {
+	if !s.opt.KeepGoing {
+		return found
+	}
+	current := make(map[string]bool)
+	var fresh []error
+	for i := range found {
+		serr := found[i].(*SimulationError)
+		key := entityRef{entity: serr.Entity, id: serr.ID}.key()
+		current[key] = true
+		if !s.violating[key] {
+			fresh = append(fresh, found[i])
+		}
+	}
+	for _, r := range checked {
+		delete(s.violating, r.key())
+	}
+	for key := range current {
+		s.violating[key] = true
+	}
+	return fresh
+}
+
+// Step simulates the next timestep. Idle timesteps are skipped unless Options.TickByTick is set.
+// It returns false if the simulation is done, the Result is available then.
+func (s *Simulation) Step() bool {
+	if s.done {
+		return false
+	}
+	w := s.w
+
+	next, ok := s.s.nextTime(w)
+	if !ok {
+		s.score()
+		return false
+	}
+	skipped := new(Int).Sub(next, &w.CurrentTime)
+	skipped.Sub(skipped, NewInt(1))
+	w.CurrentTime.Set(next)
+	s.verbose("Timestep", w.CurrentTime.String())
+
+	e := make(chan error, 1)
+	var wg sync.WaitGroup
+
+	// Trains
+	for k := range w.Trains {
+		w.Trains[k].skipTicks(skipped)
+		wg.Add(1)
+		go w.Trains[k].Update(w, e, &wg)
+	}
+
+	go func() {
+		wg.Wait()
+		close(e)
+	}()
+
+	for err := range e {
+		s.errs = append(s.errs, err)
+	}
+
+	if s.errs != nil && !s.opt.KeepGoing {
+		s.finish(invalidResult(s.errs))
+		return false
+	}
+
+	// Passengers
+	e = make(chan error, 1)
+
+	for _, p := range s.s.passengersAt(w) {
+		wg.Add(1)
+		go p.Update(w, e, &wg)
+	}
+
+	go func() {
+		wg.Wait()
+		close(e)
+	}()
+
+	for err := range e {
+		s.errs = append(s.errs, err)
+	}
+
+	if s.errs != nil && !s.opt.KeepGoing {
+		s.finish(invalidResult(s.errs))
+		return false
+	}
+
+	// Validate the entities changed in this timestep
+	s.verbose("Validate", w.CurrentTime.String())
+
+	s.errs = append(s.errs, s.validationErrors(w.validateChanges())...)
+	w.flushEvents(s.opt.Trace)
+	if s.errs != nil && !s.opt.KeepGoing {
+		s.finish(invalidResult(s.errs))
+		return false
+	}
+	return true
+}
+
+// score checks the result and calculates the delay after the last timestep.
+func (s *Simulation) score() {
+	s.verbose("Calculating score")
+
+	delay := NewInt(0)
+
+	for k := range s.w.Passengers {
+		d := s.w.Passengers[k].Delay()
+		if d.Cmp(InvalidDelay) == 0 {
+			s.errs = append(s.errs, newSimulationError(s.w, EntityPassenger, k, fmt.Errorf("passenger %s does not reach goal", k)))
+		}
+		delay.Add(delay, d)
+	}
+
+	if s.errs != nil {
+		s.finish(invalidResult(s.errs))
+		return
+	}
+
+	s.finish(Result{Valid: true, Delay: delay})
+}
+
+// finish ends the simulation with r.
+func (s *Simulation) finish(r Result) {
+	s.result = s.w.finish(r, s.opt.Trace)
+	s.done = true
+}
+
+func invalidResult(errs []error) Result {
+	SortErrors(errs)
+	return Result{Valid: false, Delay: NewInt(-1), Errors: errs}
+}
+
+// finish adds the end time and the passenger results to r.
+// Events not yet passed to trace (e.g. of an aborted timestep) are flushed.
+func (w *World) finish(r Result, trace func(Event)) Result {
+	if w.recorder != nil {
+		w.flushEvents(trace)
+		w.recorder = nil
+	}
+	r.EndTime = new(Int).Set(&w.CurrentTime)
+
+	ids := make([]string, 0, len(w.Passengers))
+	for k := range w.Passengers {
+		ids = append(ids, k)
+	}
+	SortIDs(ids)
+
+	r.Passengers = make([]PassengerResult, len(ids))
+	for i, k := range ids {
+		p := w.Passengers[k]
+		r.Passengers[i] = PassengerResult{
+			ID:         p.ID,
+			Start:      p.Start,
+			Target:     p.Target,
+			Size:       new(Int).Set(&p.Size),
+			TargetTime: new(Int).Set(&p.TargetTime),
+			Delay:      new(Int).Set(p.Delay()),
+			Legs:       make([]Leg, len(p.Legs)),
+		}
+		for j := range p.Legs {
+			r.Passengers[i].Legs[j] = Leg{Train: p.Legs[j].Train, From: p.Legs[j].From, To: p.Legs[j].To}
+			r.Passengers[i].Legs[j].Board.Set(&p.Legs[j].Board)
+			r.Passengers[i].Legs[j].Detrain.Set(&p.Legs[j].Detrain)
+		}
+		if p.TargetReached.Sign() != 0 {
+			r.Passengers[i].Arrival = new(Int).Set(&p.TargetReached)
+		}
+	}
+	return r
+}
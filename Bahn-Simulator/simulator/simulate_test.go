@@ -0,0 +1,340 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var large = flag.Bool("large", false, "also run the slow tests on test/large")
+
+// skipLarge returns whether the test case in test/dir is skipped. test/large is only used with -large.
+func skipLarge(dir string) bool {
+	return dir == "large" && !*large
+}
+
+func TestSimulate(t *testing.T) {
+	w, err := ParseInput(path.Join("..", "test", "simple", "input.txt"))
+	if err != nil {
+		t.Fatal("can not read input:", err)
+	}
+	result, err := Simulate(w, path.Join("..", "test", "simple", "output.txt"))
+	if err != nil {
+		t.Fatal("can not read plan:", err)
+	}
+	if !result.Valid {
+		t.Fatal("plan not valid:", result.Errors)
+	}
+	if result.Delay.String() != "9" {
+		t.Error("wrong delay: expected 9, got", result.Delay.String())
+	}
+}
+
+func TestSimulateReader(t *testing.T) {
+	w, err := ParseInput(path.Join("..", "test", "simple", "input.txt"))
+	if err != nil {
+		t.Fatal("can not read input:", err)
+	}
+	plan, err := os.ReadFile(path.Join("..", "test", "simple", "output.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := SimulateReader(w, bytes.NewReader(plan))
+	if err != nil {
+		t.Fatal("can not read plan:", err)
+	}
+	if !result.Valid || result.Delay.String() != "9" {
+		t.Error("wrong result:", result.Valid, result.Delay.String(), result.Errors)
+	}
+
+	w, err = ParseInput(path.Join("..", "test", "simple", "input.txt"))
+	if err != nil {
+		t.Fatal("can not read input:", err)
+	}
+	_, err = SimulateReader(w, strings.NewReader("[Train:T1]\nsomething\n"))
+	var perr ParseErrors
+	if !errors.As(err, &perr) {
+		t.Error("invalid plan not reported:", err)
+	}
+}
+
+func TestRunKeepGoing(t *testing.T) {
+	w, err := ParseInput(path.Join("..", "test", "simple", "input.txt"))
+	if err != nil {
+		t.Fatal("can not read input:", err)
+	}
+	plan := "[Train:T1]\n1 Depart L1\n2 Depart L2\n\n[Passenger:P2]\n1 Board T1\n4 Board T3\n"
+	err = ParsePlanReader(w, strings.NewReader(plan))
+	if err != nil {
+		t.Fatal("can not read plan:", err)
+	}
+
+	result := w.Run(Options{KeepGoing: true})
+	if result.Valid {
+		t.Fatal("invalid plan accepted")
+	}
+
+	grouped := GroupErrors(result.Errors)
+	if len(grouped[EntityTrain]["T1"]) != 1 {
+		t.Errorf("expected one error for T1, got %v", grouped[EntityTrain]["T1"])
+	}
+	// Boarding T1 fails at 1 (no boarding on departure tick), boarding unknown T3 fails at 4, P1 and P2 do not reach their goal
+	if len(grouped[EntityPassenger]["P2"]) != 3 || len(grouped[EntityPassenger]["P1"]) != 1 {
+		t.Errorf("wrong passenger errors: %v", grouped[EntityPassenger])
+	}
+}
+
+func TestRunDeterministic(t *testing.T) {
+	// Both passengers fail at the same timestep; a train and a passenger fail later on
+	plan := "[Train:T1]\n1 Depart L1\n2 Depart L2\n\n[Train:T2]\n0 Start S2\n1 Depart L1\n3 Depart L1\n\n[Passenger:P1]\n1 Board T2\n6 Detrain\n\n[Passenger:P2]\n1 Board T1\n3 Detrain\n4 Board T3\n"
+
+	for _, keepGoing := range []bool{false, true} {
+		var expected string
+		for i := 0; i < 100; i++ {
+			w, err := ParseInput(path.Join("..", "test", "simple", "input.txt"))
+			if err != nil {
+				t.Fatal("can not read input:", err)
+			}
+			err = ParsePlanReader(w, strings.NewReader(plan))
+			if err != nil {
+				t.Fatal("can not read plan:", err)
+			}
+			result := w.Run(Options{KeepGoing: keepGoing})
+
+			var b strings.Builder
+			for j := range result.Errors {
+				b.WriteString(result.Errors[j].Error())
+				b.WriteString("\n")
+			}
+			if i == 0 {
+				expected = b.String()
+				if len(result.Errors) < 2 {
+					t.Fatalf("expected multiple errors, got %v", result.Errors)
+				}
+				continue
+			}
+			if b.String() != expected {
+				t.Fatalf("output differs between runs (keep going: %v):\n%s\n---\n%s", keepGoing, expected, b.String())
+			}
+		}
+	}
+}
+
+func TestRunTrace(t *testing.T) {
+	var expected []string
+	for i := 0; i < 20; i++ {
+		w, err := ParseInput(path.Join("..", "test", "simple", "input.txt"))
+		if err != nil {
+			t.Fatal("can not read input:", err)
+		}
+		err = ParsePlan(w, path.Join("..", "test", "simple", "output.txt"))
+		if err != nil {
+			t.Fatal("can not read plan:", err)
+		}
+
+		var events []string
+		w.Run(Options{Trace: func(e Event) {
+			events = append(events, fmt.Sprintf("%s %s %s %s %s %s %s", e.Time.String(), e.Type, e.Train, e.Passenger, e.Line, e.Station, e.Count.String()))
+		}})
+
+		if i == 0 {
+			expected = events
+			if len(events) != 20 || events[0] != "0 start T2   S2 0" || events[len(events)-1] != "6 target_reached  P1  S3 0" {
+				t.Fatalf("wrong events: %q", events)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(events, expected) {
+			t.Fatalf("events differ between runs:\n%q\n%q", expected, events)
+		}
+	}
+}
+
+func TestSimulationRestore(t *testing.T) {
+	for _, dir := range []string{"simple", "kapazität", "stationCapacity", "testLineForthBack", "unusedWildcardTrain"} {
+		w, err := ParseInput(path.Join("..", "test", dir, "input.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ParsePlan(w, path.Join("..", "test", dir, "output.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s := w.NewSimulation(Options{})
+		var checkpoints []*Checkpoint
+		for {
+			checkpoints = append(checkpoints, s.Checkpoint())
+			if !s.Step() {
+				break
+			}
+		}
+		want := s.Result()
+
+		// Resume from every checkpoint, going backwards
+		for i := len(checkpoints) - 1; i >= 0; i-- {
+			if err := s.Restore(checkpoints[i]); err != nil {
+				t.Fatal(err)
+			}
+			for s.Step() {
+			}
+			got := s.Result()
+			if got.Valid != want.Valid || got.Delay.Cmp(want.Delay) != 0 || !reflect.DeepEqual(got.Passengers, want.Passengers) {
+				t.Errorf("%s: different result when resuming at %s: %v %s", dir, checkpoints[i].Time(), got.Valid, got.Delay)
+			}
+		}
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	input, err := os.ReadFile(path.Join("..", "test", "simple", "input.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := os.ReadFile(path.Join("..", "test", "simple", "output.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// P1 arrives one timestep later, which only changes the plan after the checkpoint
+	whatIf := strings.Replace(string(plan), "6 Detrain", "7 Detrain", 1)
+	world := func(plan string) *World {
+		w, err := ParseInputReader(bytes.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ParsePlanReader(w, strings.NewReader(plan))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+
+	var c *Checkpoint
+	w := world(string(plan))
+	want := w.Run(Options{CheckpointAt: *NewInt(3), Checkpoint: func(cp *Checkpoint) { c = cp }})
+	if c == nil || c.Time().String() != "3" {
+		t.Fatal("no checkpoint at 3")
+	}
+
+	// The checkpoint must not change with the world
+	w.Trains["T1"].Position[0] = "changed"
+	if c.Snapshot.Trains["T1"].Position[0] == "changed" {
+		t.Error("snapshot shares memory with world")
+	}
+
+	var b bytes.Buffer
+	_, err = c.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = ReadCheckpoint(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := world(string(plan)).Run(Options{Resume: c})
+	if !got.Valid || got.Delay.Cmp(want.Delay) != 0 || !reflect.DeepEqual(got.Passengers, want.Passengers) {
+		t.Errorf("different result after resume: %v %s %v", got.Valid, got.Delay, got.Errors)
+	}
+
+	want = world(whatIf).Run(Options{})
+	got = world(whatIf).Run(Options{Resume: c})
+	if !got.Valid || got.Delay.String() != "12" || got.Delay.Cmp(want.Delay) != 0 {
+		t.Errorf("wrong what-if result: %v %s, expected %s", got.Valid, got.Delay, want.Delay)
+	}
+
+	input = []byte(strings.Replace(string(input), "P2 S2 S1 10 3\n", "", 1))
+	if r := world("").Run(Options{Resume: c}); r.Valid {
+		t.Error("resumed with checkpoint of different world")
+	}
+}
+
+func TestCheckpointMalformed(t *testing.T) {
+	input, err := os.ReadFile(path.Join("..", "test", "simple", "input.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := os.ReadFile(path.Join("..", "test", "simple", "output.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	world := func() *World {
+		w, err := ParseInputReader(bytes.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ParsePlanReader(w, bytes.NewReader(plan))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+
+	var c *Checkpoint
+	world().Run(Options{CheckpointAt: *NewInt(3), Checkpoint: func(cp *Checkpoint) { c = cp }})
+	var b bytes.Buffer
+	_, err = c.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := b.String()
+
+	// T1 is at S1, T2 on L1 to S3, P1 in T2 and P2 at S1
+	for name, change := range map[string][2]string{
+		"station without position": {`"position": [
+        "S1"
+      ]`, `"position": []`},
+		"unknown station": {`"S1"
+      ]`, `"S9"
+      ]`},
+		"line with one position": {`"L1",
+        "S3"`, `"L1"`},
+		"unknown line": {`"L1",`, `"L9",`},
+		"line not ending at target": {`"L1",
+        "S3"`, `"L1",
+        "S4"`},
+		"unknown position type":      {`"position_type": 1`, `"position_type": 7`},
+		"negative passengers":        {`"passengers": 3`, `"passengers": -3`},
+		"passenger in unknown train": {`"position": "T2"`, `"position": "T9"`},
+		"leg of unknown train":       {`"train": "T2"`, `"train": "T9"`},
+		"negative station count":     {`"S1": 1`, `"S1": -1`},
+		"negative time":              {`"time": 3`, `"time": -3`},
+	} {
+		if !strings.Contains(valid, change[0]) {
+			t.Fatalf("%s: checkpoint does not contain %s:\n%s", name, change[0], valid)
+		}
+		c, err := ReadCheckpoint(strings.NewReader(strings.Replace(valid, change[0], change[1], 1)))
+		if err != nil {
+			// Some changes are already rejected while reading
+			continue
+		}
+		w := world()
+		if err := w.Restore(c.Snapshot); err == nil {
+			t.Errorf("%s: malformed snapshot restored", name)
+		}
+		if r := w.Run(Options{Resume: c}); r.Valid {
+			t.Errorf("%s: resumed from malformed checkpoint", name)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+type Entity int
+
+const (
+	EntityWorld Entity = iota
+	EntityTrain
+	EntityPassenger
+	EntityLine
+	EntityStation
+)
+
+func (e Entity) String() string {
+	switch e {
+	case EntityTrain:
+		return "train"
+	case EntityPassenger:
+		return "passenger"
+	case EntityLine:
+		return "line"
+	case EntityStation:
+		return "station"
+	default:
+		return "world"
+	}
+}
+
+// SimulationError describes a rule violation of a single entity at a single timestep.
+// Violations found before the simulation starts have time 0.
+type SimulationError struct {
+	Time   Int
+	Entity Entity
+	ID     string
+	Err    error
+}
+
+func (e *SimulationError) Error() string {
+	return fmt.Sprintf("%s - %s", e.Time.String(), e.Err.Error())
+}
+
+func (e *SimulationError) Unwrap() error {
+	return e.Err
+}
+
+func newSimulationError(w *World, entity Entity, id string, err error) *SimulationError {
+	e := &SimulationError{Entity: entity, ID: id, Err: err}
+	e.Time.Set(&w.CurrentTime)
+	return e
+}
+
+// GroupErrors groups errors by entity and id.
+// Errors which are not a *SimulationError are grouped under EntityWorld.
+func GroupErrors(errs []error) map[Entity]map[string][]error {
+	grouped := make(map[Entity]map[string][]error)
+	for i := range errs {
+		entity, id := EntityWorld, ""
+		var serr *SimulationError
+		if errors.As(errs[i], &serr) {
+			entity, id = serr.Entity, serr.ID
+		}
+		if grouped[entity] == nil {
+			grouped[entity] = make(map[string][]error)
+		}
+		grouped[entity][id] = append(grouped[entity][id], errs[i])
+	}
+	return grouped
+}
+
+// SortErrors sorts errors by time, entity and id (in natural order).
+// The order of errors which are equal in all three is kept.
+// Errors which are not a *SimulationError are sorted to the end.
+func SortErrors(errs []error) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		var a, b *SimulationError
+		okA, okB := errors.As(errs[i], &a), errors.As(errs[j], &b)
+		if !okA || !okB {
+			return okA && !okB
+		}
+		if c := a.Time.Cmp(&b.Time); c != 0 {
+			return c < 0
+		}
+		if a.Entity != b.Entity {
+			return a.Entity < b.Entity
+		}
+		return CompareIDs(a.ID, b.ID) < 0
+	})
+}
@@ -0,0 +1,334 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Snapshot is the mutable state of a world at a timestep.
+// It is a deep copy, so it does not share any memory (including locks and arbitrary precision values) with the world.
+// Plans are not part of a snapshot, so a snapshot can be restored into a world with a different plan.
+type Snapshot struct {
+	Time       Int
+	Trains     map[string]*TrainSnapshot
+	Passengers map[string]*PassengerSnapshot
+	// Lines contains the number of trains on each line. Lines without trains are omitted.
+	Lines map[string]*Int
+	// Stations contains the number of trains at each station.
+	Stations map[string]*Int
+}
+
+// TrainSnapshot is the state of a train.
+type TrainSnapshot struct {
+	Passengers       Int
+	Position         []string
+	PositionSince    Rat
+	PositionType     TrainPosition
+	BoardingPossible bool
+}
+
+// PassengerSnapshot is the state of a passenger.
+type PassengerSnapshot struct {
+	TargetReached Int
+	PositionType  PassengerPosition
+	Position      string
+	Legs          []Leg
+}
+
+// Snapshot returns the current state of w. It must not be called while w is updated.
+func (w *World) Snapshot() *Snapshot {
+	s := &Snapshot{
+		Time:       w.CurrentTime.clone(),
+		Trains:     make(map[string]*TrainSnapshot, len(w.Trains)),
+		Passengers: make(map[string]*PassengerSnapshot, len(w.Passengers)),
+		Lines:      make(map[string]*Int),
+		Stations:   make(map[string]*Int, len(w.Stations)),
+	}
+	for k, t := range w.Trains {
+		s.Trains[k] = &TrainSnapshot{
+			Passengers:       t.Passengers.clone(),
+			Position:         append([]string(nil), t.Position...),
+			PositionSince:    t.PositionSince.clone(),
+			PositionType:     t.PositionType,
+			BoardingPossible: t.BoardingPossible,
+		}
+	}
+	for k, p := range w.Passengers {
+		s.Passengers[k] = &PassengerSnapshot{
+			TargetReached: p.TargetReached.clone(),
+			PositionType:  p.PositionType,
+			Position:      p.Position,
+			Legs:          cloneLegs(p.Legs),
+		}
+	}
+	for k, l := range w.Lines {
+		if l.CurrentCapacity.Sign() != 0 {
+			c := l.CurrentCapacity.clone()
+			s.Lines[k] = &c
+		}
+	}
+	for k, st := range w.Stations {
+		c := st.CurrenTrains.clone()
+		s.Stations[k] = &c
+	}
+	return s
+}
+
+// Restore sets the state of w to s. The plans of w are continued after the time of s.
+// An error is returned if s does not fit w, w is not changed in this case. s fits w if it contains exactly the trains,
+// passengers and stations of w, all positions and legs reference existing entities and no time or count is negative.
+func (w *World) Restore(s *Snapshot) error {
+	err := w.checkSnapshot(s)
+	if err != nil {
+		return err
+	}
+
+	w.CurrentTime = s.Time.clone()
+	w.changes.entities = nil
+	for k, t := range w.Trains {
+		state := s.Trains[k]
+		t.Passengers = state.Passengers.clone()
+		t.Position = append([]string(nil), state.Position...)
+		t.PositionSince = state.PositionSince.clone()
+		t.PositionType = state.PositionType
+		t.BoardingPossible = state.BoardingPossible
+		t.nextAction = sort.Search(len(t.Plan), func(i int) bool { return t.Plan[i].Time.Cmp(&s.Time) == +1 })
+	}
+	for k, p := range w.Passengers {
+		state := s.Passengers[k]
+		p.TargetReached = state.TargetReached.clone()
+		p.PositionType = state.PositionType
+		p.Position = state.Position
+		p.Legs = cloneLegs(state.Legs)
+		p.nextAction = sort.Search(len(p.Plan), func(i int) bool { return p.Plan[i].Time.Cmp(&s.Time) == +1 })
+	}
+	for k, l := range w.Lines {
+		l.CurrentCapacity = Int{}
+		if c, ok := s.Lines[k]; ok {
+			l.CurrentCapacity = c.clone()
+		}
+	}
+	for k, st := range w.Stations {
+		st.CurrenTrains = s.Stations[k].clone()
+	}
+	return nil
+}
+
+// checkSnapshot returns an error if s does not fit w, see Restore.
+func (w *World) checkSnapshot(s *Snapshot) error {
+	if len(s.Trains) != len(w.Trains) || len(s.Passengers) != len(w.Passengers) || len(s.Stations) != len(w.Stations) {
+		return fmt.Errorf("snapshot does not fit the world")
+	}
+	if s.Time.Sign() == -1 {
+		return fmt.Errorf("snapshot time '%s' must not be negative", s.Time.String())
+	}
+	for k, t := range s.Trains {
+		if _, ok := w.Trains[k]; !ok {
+			return fmt.Errorf("snapshot contains unknown train '%s'", k)
+		}
+		err := w.checkTrainSnapshot(t)
+		if err != nil {
+			return fmt.Errorf("train '%s' in snapshot: %w", k, err)
+		}
+	}
+	for k, p := range s.Passengers {
+		if _, ok := w.Passengers[k]; !ok {
+			return fmt.Errorf("snapshot contains unknown passenger '%s'", k)
+		}
+		err := w.checkPassengerSnapshot(p)
+		if err != nil {
+			return fmt.Errorf("passenger '%s' in snapshot: %w", k, err)
+		}
+	}
+	for k, c := range s.Stations {
+		if _, ok := w.Stations[k]; !ok {
+			return fmt.Errorf("snapshot contains unknown station '%s'", k)
+		}
+		if c == nil || c.Sign() == -1 {
+			return fmt.Errorf("number of trains at station '%s' in snapshot must not be negative", k)
+		}
+	}
+	for k, c := range s.Lines {
+		if _, ok := w.Lines[k]; !ok {
+			return fmt.Errorf("snapshot contains unknown line '%s'", k)
+		}
+		if c == nil || c.Sign() == -1 {
+			return fmt.Errorf("number of trains on line '%s' in snapshot must not be negative", k)
+		}
+	}
+	return nil
+}
+
+func (w *World) checkTrainSnapshot(t *TrainSnapshot) error {
+	if t == nil {
+		return fmt.Errorf("missing state")
+	}
+	switch t.PositionType {
+	case TrainPositionStation:
+		if len(t.Position) != 1 {
+			return fmt.Errorf("position at a station needs 1 entry, got %d", len(t.Position))
+		}
+		if _, ok := w.Stations[t.Position[0]]; !ok {
+			return fmt.Errorf("unknown station '%s'", t.Position[0])
+		}
+	case TrainPositionLine:
+		if len(t.Position) != 2 {
+			return fmt.Errorf("position on a line needs 2 entries, got %d", len(t.Position))
+		}
+		l, ok := w.Lines[t.Position[0]]
+		if !ok {
+			return fmt.Errorf("unknown line '%s'", t.Position[0])
+		}
+		if _, ok := l.OtherEnd(t.Position[1]); !ok {
+			return fmt.Errorf("line '%s' does not end at '%s'", l.ID, t.Position[1])
+		}
+	case TrainPositionWildcard:
+		if len(t.Position) != 1 || t.Position[0] != "*" {
+			return fmt.Errorf("position of a train at '*' must be '*'")
+		}
+	default:
+		return fmt.Errorf("unknown position type %d", t.PositionType)
+	}
+	if t.Passengers.Sign() == -1 {
+		return fmt.Errorf("passengers must not be negative")
+	}
+	if t.PositionSince.Sign() == -1 {
+		return fmt.Errorf("position since must not be negative")
+	}
+	return nil
+}
+
+func (w *World) checkPassengerSnapshot(p *PassengerSnapshot) error {
+	if p == nil {
+		return fmt.Errorf("missing state")
+	}
+	switch p.PositionType {
+	case PassengerPositionStation:
+		if _, ok := w.Stations[p.Position]; !ok {
+			return fmt.Errorf("unknown station '%s'", p.Position)
+		}
+	case PassengerPositionTrain:
+		if _, ok := w.Trains[p.Position]; !ok {
+			return fmt.Errorf("unknown train '%s'", p.Position)
+		}
+	default:
+		return fmt.Errorf("unknown position type %d", p.PositionType)
+	}
+	if p.TargetReached.Sign() == -1 {
+		return fmt.Errorf("target reached must not be negative")
+	}
+	for i := range p.Legs {
+		l := &p.Legs[i]
+		if _, ok := w.Trains[l.Train]; !ok {
+			return fmt.Errorf("leg with unknown train '%s'", l.Train)
+		}
+		if _, ok := w.Stations[l.From]; !ok {
+			return fmt.Errorf("leg from unknown station '%s'", l.From)
+		}
+		if _, ok := w.Stations[l.To]; l.To != "" && !ok {
+			return fmt.Errorf("leg to unknown station '%s'", l.To)
+		}
+		if l.Board.Sign() == -1 || l.Detrain.Sign() == -1 {
+			return fmt.Errorf("leg times must not be negative")
+		}
+	}
+	return nil
+}
+
+func cloneLegs(legs []Leg) []Leg {
+	if legs == nil {
+		return nil
+	}
+	c := make([]Leg, len(legs))
+	for i := range legs {
+		c[i] = Leg{Train: legs[i].Train, From: legs[i].From, Board: legs[i].Board.clone(), To: legs[i].To, Detrain: legs[i].Detrain.clone()}
+	}
+	return c
+}
+
+// clone returns a deep copy of w including the plans, so that it can be simulated without changing w.
+// The network is built again on first use.
+func (w *World) clone() *World {
+	c := &World{
+		Lines:       make(map[string]*Line, len(w.Lines)),
+		Stations:    make(map[string]*Station, len(w.Stations)),
+		Trains:      make(map[string]*Train, len(w.Trains)),
+		Passengers:  make(map[string]*Passenger, len(w.Passengers)),
+		CurrentTime: w.CurrentTime.clone(),
+		MaxTime:     w.MaxTime.clone(),
+	}
+	for k, l := range w.Lines {
+		c.Lines[k] = &Line{
+			ID:              l.ID,
+			End:             append([]string(nil), l.End...),
+			Length:          l.Length.clone(),
+			MaxCapacity:     l.MaxCapacity.clone(),
+			CurrentCapacity: l.CurrentCapacity.clone(),
+		}
+	}
+	for k, st := range w.Stations {
+		c.Stations[k] = &Station{ID: st.ID, Capacity: st.Capacity.clone(), CurrenTrains: st.CurrenTrains.clone()}
+	}
+	for k, t := range w.Trains {
+		c.Trains[k] = &Train{
+			ID:               t.ID,
+			Capacity:         t.Capacity.clone(),
+			Passengers:       t.Passengers.clone(),
+			Speed:            t.Speed.clone(),
+			Position:         append([]string(nil), t.Position...),
+			PositionSince:    t.PositionSince.clone(),
+			PositionType:     t.PositionType,
+			Plan:             cloneTrainPlan(t.Plan),
+			BoardingPossible: t.BoardingPossible,
+			Wildcard:         t.Wildcard,
+			nextAction:       t.nextAction,
+		}
+	}
+	for k, p := range w.Passengers {
+		c.Passengers[k] = &Passenger{
+			ID:            p.ID,
+			Start:         p.Start,
+			Target:        p.Target,
+			Size:          p.Size.clone(),
+			TargetTime:    p.TargetTime.clone(),
+			TargetReached: p.TargetReached.clone(),
+			PositionType:  p.PositionType,
+			Position:      p.Position,
+			Plan:          clonePassengerPlan(p.Plan),
+			Legs:          cloneLegs(p.Legs),
+			nextAction:    p.nextAction,
+		}
+	}
+	return c
+}
+
+func cloneTrainPlan(plan []TrainAction) []TrainAction {
+	c := make([]TrainAction, len(plan))
+	for i := range plan {
+		c[i] = TrainAction{Time: plan[i].Time.clone(), Type: plan[i].Type, Target: plan[i].Target}
+	}
+	return c
+}
+
+func clonePassengerPlan(plan []PassengerAction) []PassengerAction {
+	c := make([]PassengerAction, len(plan))
+	for i := range plan {
+		c[i] = PassengerAction{Time: plan[i].Time.clone(), Type: plan[i].Type, Train: plan[i].Train}
+	}
+	return c
+}
@@ -0,0 +1,453 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Solve creates plans for all trains and passengers of w, which must not have plans yet.
+//
+// It is a simple greedy baseline. Passengers with the same start and target are grouped, groups are served in order
+// of their earliest target time. For each group, the train which can deliver the first passenger first is moved to
+// the start, as many passengers of the group as fit board, and the train takes the fastest path to the target.
+// Only one train moves at any time, so line capacities can not be exceeded. Paths avoid full stations. If no train
+// can serve a passenger, parked trains are moved away from the full stations on the route of a train.
+// Wildcard trains are started at the stations where most passengers start.
+//
+// Wildcard trains are placed at their start station like ParsePlan does, so w can be simulated directly.
+// An error is returned if not all passengers can be served. The plans of w are incomplete in this case.
+func Solve(w *World) error {
+	s := &solver{
+		w:     w,
+		r:     NewRouter(w),
+		now:   *NewInt(1),
+		count: make(map[string]*Int, len(w.Stations)),
+	}
+	for k := range w.Stations {
+		s.count[k] = new(Int).Set(&w.Stations[k].CurrenTrains)
+		if !s.spare(k) {
+			s.full++
+		}
+	}
+
+	groups := s.groups()
+	s.placeTrains(groups)
+	if len(s.trains) == 0 && len(groups) != 0 {
+		return fmt.Errorf("no train available")
+	}
+
+	for _, g := range groups {
+		for len(g) != 0 {
+			j, ok := s.bestJob(g[0])
+			if !ok {
+				// Stations might be blocked by parked trains
+				s.clearRoute(g[0])
+				j, ok = s.bestJob(g[0])
+			}
+			if !ok {
+				return fmt.Errorf("can not find a train for passenger %s", g[0].ID)
+			}
+			g = s.apply(j, g)
+		}
+	}
+	w.UpdateMaxTime()
+	return nil
+}
+
+type solver struct {
+	w *World
+	r *Router
+	// now is the earliest time for the next departure, all earlier movements are finished
+	now Int
+	// count is the number of trains at each station
+	count  map[string]*Int
+	trains []*solverTrain
+	// full is the number of stations without space left
+	full int
+	// trees caches the path trees avoiding full stations by source and speed until full stations change
+	trees map[[2]string]*pathTree
+}
+
+// solverTrain is a train started at a station.
+type solverTrain struct {
+	t       *Train
+	station string
+	// ready is the earliest time for the next action of the train or of passengers boarding it
+	ready Int
+}
+
+// job is the transport of passengers from start to target by a train.
+type job struct {
+	train         *solverTrain
+	start, target string
+	depart        Int
+	pickup        Path
+	board         Int
+	delivery      Path
+	detrain       Int
+}
+
+// groups returns all passengers grouped by start and target. Groups are sorted by their earliest target time,
+// passengers in groups by target time.
+func (s *solver) groups() [][]*Passenger {
+	ids := make([]string, 0, len(s.w.Passengers))
+	for k := range s.w.Passengers {
+		ids = append(ids, k)
+	}
+	SortIDs(ids)
+	sort.SliceStable(ids, func(i, j int) bool {
+		return s.w.Passengers[ids[i]].TargetTime.Cmp(&s.w.Passengers[ids[j]].TargetTime) == -1
+	})
+
+	index := make(map[[2]string]int)
+	var groups [][]*Passenger
+	for _, k := range ids {
+		p := s.w.Passengers[k]
+		key := [2]string{p.Start, p.Target}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], p)
+	}
+	return groups
+}
+
+// spare returns whether another train fits into station.
+func (s *solver) spare(station string) bool {
+	st, ok := s.w.Stations[station]
+	if !ok {
+		return false
+	}
+	return s.count[station].Cmp(&st.Capacity) == -1
+}
+
+// placeTrains collects all trains at stations and starts wildcard trains where most passengers are waiting.
+func (s *solver) placeTrains(groups [][]*Passenger) {
+	demand := make(map[string]*Int)
+	for _, g := range groups {
+		for _, p := range g {
+			if demand[p.Start] == nil {
+				demand[p.Start] = new(Int)
+			}
+			demand[p.Start].Add(demand[p.Start], &p.Size)
+		}
+	}
+	stations := make([]string, 0, len(demand))
+	for k := range demand {
+		stations = append(stations, k)
+	}
+	SortIDs(stations)
+
+	ids := make([]string, 0, len(s.w.Trains))
+	for k := range s.w.Trains {
+		ids = append(ids, k)
+	}
+	SortIDs(ids)
+	for _, k := range ids {
+		t := s.w.Trains[k]
+		switch t.PositionType {
+		case TrainPositionStation:
+			s.trains = append(s.trains, &solverTrain{t: t, station: t.Position[0], ready: *NewInt(1)})
+			if d, ok := demand[t.Position[0]]; ok {
+				d.Sub(d, &t.Capacity)
+			}
+		case TrainPositionWildcard:
+			best := ""
+			for _, station := range stations {
+				if demand[station].Sign() != +1 || !s.spare(station) {
+					continue
+				}
+				if best == "" || demand[station].Cmp(demand[best]) == +1 {
+					best = station
+				}
+			}
+			if best == "" {
+				continue
+			}
+			// Placed like ParsePlan does, WritePlan writes the 'Start' action
+			st := s.w.Stations[best]
+			t.Position = []string{st.ID}
+			t.PositionType = TrainPositionStation
+			st.CurrenTrains.Add(&st.CurrenTrains, NewInt(1))
+			s.add(best, 1)
+			demand[best].Sub(demand[best], &t.Capacity)
+			s.trains = append(s.trains, &solverTrain{t: t, station: best, ready: *NewInt(1)})
+		}
+	}
+}
+
+// bestJob returns the job which delivers p first.
+//
+// Paths avoiding full stations are expensive to compute, so trains are tried in order of the detrain time
+// without any full stations, which is a lower bound.
+func (s *solver) bestJob(p *Passenger) (job, bool) {
+	var best job
+	found := false
+	for _, c := range s.candidates(p) {
+		if found && c.bound.Cmp(&best.detrain) != -1 {
+			break
+		}
+		j, ok := s.plan(c.train, p.Start, p.Target)
+		if !ok {
+			continue
+		}
+		if !found || j.detrain.Cmp(&best.detrain) == -1 {
+			best, found = j, true
+		}
+	}
+	return best, found
+}
+
+// candidate is a train which can serve a passenger together with the lower bound of the detrain time.
+type candidate struct {
+	train *solverTrain
+	bound *Int
+}
+
+// candidates returns all trains which can serve p ordered by the detrain time without any full stations.
+func (s *solver) candidates(p *Passenger) []candidate {
+	var candidates []candidate
+	for _, st := range s.trains {
+		if st.t.Capacity.Cmp(&p.Size) == -1 {
+			continue
+		}
+		bound := new(Int).Set(&s.now)
+		if bound.Cmp(&st.ready) == -1 {
+			bound.Set(&st.ready)
+		}
+		_, pickup, ok := s.r.FastestPath(st.station, p.Start, &st.t.Speed)
+		if !ok {
+			continue
+		}
+		_, delivery, ok := s.r.FastestPath(p.Start, p.Target, &st.t.Speed)
+		if !ok {
+			continue
+		}
+		bound.Add(bound, pickup)
+		bound.Add(bound, delivery)
+		if p.Start != p.Target {
+			bound.Add(bound, NewInt(1))
+		}
+		candidates = append(candidates, candidate{train: st, bound: bound})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].bound.Cmp(candidates[j].bound) == -1
+	})
+	return candidates
+}
+
+// plan returns the job of moving train st to start and from there to target.
+func (s *solver) plan(st *solverTrain, start, target string) (job, bool) {
+	j := job{train: st, start: start, target: target}
+	j.depart.Set(&s.now)
+	if j.depart.Cmp(&st.ready) == -1 {
+		j.depart.Set(&st.ready)
+	}
+
+	// The station of the train is freed when it departs
+	full := func(station string) bool { return station != st.station && !s.spare(station) }
+
+	j.board.Set(&j.depart)
+	if st.station != start {
+		if full(start) {
+			return job{}, false
+		}
+		path, ticks, ok := s.path(st.station, start, &st.t.Speed, full)
+		if !ok {
+			return job{}, false
+		}
+		j.pickup = path
+		j.board.Add(&j.board, ticks)
+	}
+
+	// The train departs one timestep after boarding
+	j.detrain.Add(&j.board, NewInt(1))
+	if start != target {
+		if full(target) {
+			return job{}, false
+		}
+		path, ticks, ok := s.path(start, target, &st.t.Speed, full)
+		if !ok {
+			return job{}, false
+		}
+		j.delivery = path
+		j.detrain.Add(&j.detrain, ticks)
+	}
+	return j, true
+}
+
+// path returns the fastest path which does not pass stations for which full returns true.
+//
+// Paths avoiding all full stations are cached until a station becomes full or gets space again,
+// the exact search is only used if no such path exists.
+func (s *solver) path(from, to string, speed *Rat, full func(station string) bool) (Path, *Int, bool) {
+	if s.full == 0 {
+		return s.r.FastestPath(from, to, speed)
+	}
+	skip := func(node int) bool { return !s.spare(s.r.n.ids[node]) }
+	key := [2]string{from, speed.String()}
+	tree, ok := s.trees[key]
+	if !ok {
+		tree, ok = s.r.avoidingTree(from, speed, skip)
+		if !ok {
+			return Path{}, nil, false
+		}
+		if s.trees == nil {
+			s.trees = make(map[[2]string]*pathTree)
+		}
+		s.trees[key] = tree
+	}
+	path, ticks, ok := s.r.fastestTo(tree, to, speed, skip)
+	if ok {
+		return path, ticks, true
+	}
+	return s.r.FastestPathAvoiding(from, to, speed, full)
+}
+
+// departures adds the departures along path starting at time t to the plan of train and returns the time
+// of the last departure.
+func (s *solver) departures(train *Train, path Path, t *Int) *Int {
+	depart := new(Int).Set(t)
+	last := new(Int).Set(t)
+	for _, id := range path.Lines {
+		last.Set(depart)
+		train.AddAction(TrainAction{Time: *new(Int).Set(depart), Type: TrainActionDepart, Target: id})
+		k, _ := train.TravelTime(s.w.Lines[id])
+		depart.Add(depart, readyTime(k))
+	}
+	return last
+}
+
+// move updates the position of train st to station.
+func (s *solver) move(st *solverTrain, station string) {
+	s.add(st.station, -1)
+	s.add(station, 1)
+	st.station = station
+}
+
+// add changes the number of trains at station by n.
+func (s *solver) add(station string, n int64) {
+	spare := s.spare(station)
+	s.count[station].Add(s.count[station], NewInt(n))
+	if spare == s.spare(station) {
+		return
+	}
+	if spare {
+		s.full++
+	} else {
+		s.full--
+	}
+	s.trees = nil
+}
+
+// apply adds j to the plans. It returns the passengers of g which are not part of the job.
+func (s *solver) apply(j job, g []*Passenger) []*Passenger {
+	st := j.train
+	s.departures(st.t, j.pickup, &j.depart)
+	if len(j.delivery.Lines) != 0 {
+		s.departures(st.t, j.delivery, new(Int).Add(&j.board, NewInt(1)))
+	}
+
+	var remaining []*Passenger
+	load := new(Int)
+	for _, p := range g {
+		next := new(Int).Add(load, &p.Size)
+		if next.Cmp(&st.t.Capacity) == +1 {
+			remaining = append(remaining, p)
+			continue
+		}
+		load = next
+		p.AddAction(PassengerAction{Time: *new(Int).Set(&j.board), Type: PassengerActionBoard, Train: st.t.ID})
+		p.AddAction(PassengerAction{Time: *new(Int).Set(&j.detrain), Type: PassengerActionDetrain})
+	}
+
+	s.move(st, j.target)
+	st.ready.Add(&j.detrain, NewInt(1))
+	s.now.Set(&j.detrain)
+	return remaining
+}
+
+// clearRoute moves parked trains away from full stations on the fastest route of a train serving p, which ignores
+// full stations. Trains are tried in the order of bestJob until the route of one train is cleared.
+func (s *solver) clearRoute(p *Passenger) {
+	for _, c := range s.candidates(p) {
+		st := c.train
+		pickup, _, _ := s.r.FastestPath(st.station, p.Start, &st.t.Speed)
+		delivery, _, _ := s.r.FastestPath(p.Start, p.Target, &st.t.Speed)
+		route := make(map[string]bool)
+		for _, station := range append(pickup.Stations, delivery.Stations...) {
+			route[station] = true
+		}
+		cleared := true
+		for _, station := range append(pickup.Stations, delivery.Stations...) {
+			if station == st.station || s.spare(station) {
+				continue
+			}
+			if !s.evict(station, st, route) {
+				cleared = false
+				break
+			}
+		}
+		if cleared {
+			return
+		}
+	}
+}
+
+// evict moves a train other than keep from station to the nearest station with space left which is not part
+// of route. It returns false if no train can be moved.
+func (s *solver) evict(station string, keep *solverTrain, route map[string]bool) bool {
+	skip := func(node int) bool { return !s.spare(s.r.n.ids[node]) }
+	for _, st := range s.trains {
+		if st == keep || st.station != station {
+			continue
+		}
+		tree, ok := s.r.avoidingTree(station, &st.t.Speed, skip)
+		if !ok {
+			continue
+		}
+		var best Path
+		var bestTicks *Int
+		for _, target := range s.r.n.Stations() {
+			if route[target] || !s.spare(target) {
+				continue
+			}
+			path, ticks, ok := s.r.fastestTo(tree, target, &st.t.Speed, skip)
+			if ok && (bestTicks == nil || ticks.Cmp(bestTicks) == -1) {
+				best, bestTicks = path, ticks
+			}
+		}
+		if bestTicks == nil {
+			continue
+		}
+		depart := new(Int).Set(&s.now)
+		if depart.Cmp(&st.ready) == -1 {
+			depart.Set(&st.ready)
+		}
+		s.departures(st.t, best, depart)
+		arrival := new(Int).Add(depart, bestTicks)
+		s.move(st, best.Stations[len(best.Stations)-1])
+		st.ready.Set(arrival)
+		s.now.Set(arrival)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// solveAndRun solves input and simulates the written plan.
+func solveAndRun(t *testing.T, input string) Result {
+	t.Helper()
+	w, err := ParseInputReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Solve(w)
+	if err != nil {
+		t.Fatal("can not solve:", err)
+	}
+	var plan bytes.Buffer
+	err = WritePlan(w, &plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err = ParseInputReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ParsePlanReader(w, &plan)
+	if err != nil {
+		t.Fatal("can not parse written plan:", err)
+	}
+	return w.Run(Options{})
+}
+
+func TestSolve(t *testing.T) {
+	dirs, err := os.ReadDir(path.Join("..", "test"))
+	if err != nil {
+		t.Fatal("can not read test dir:", err)
+	}
+	for i := range dirs {
+		if !dirs[i].IsDir() || skipLarge(dirs[i].Name()) {
+			continue
+		}
+		input, err := os.ReadFile(path.Join("..", "test", dirs[i].Name(), "input.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := solveAndRun(t, string(input))
+		if !r.Valid {
+			t.Errorf("%s: invalid plan: %v", dirs[i].Name(), r.Errors)
+		}
+		t.Log(dirs[i].Name(), r.Delay)
+	}
+
+	input, _ := syntheticWorld(20, 100)
+	if r := solveAndRun(t, input); !r.Valid {
+		t.Errorf("synthetic: invalid plan: %v", r.Errors)
+	}
+
+	// Small generated instances, parked trains block stations and whole routes
+	for _, topology := range Topologies {
+		for seed := int64(1); seed <= 8; seed++ {
+			t.Run(fmt.Sprintf("%s/%d", topology, seed), func(t *testing.T) {
+				opt := DefaultGenerateOptions()
+				opt.Topology, opt.Seed = topology, seed
+				opt.Stations, opt.Trains, opt.Passengers, opt.Wildcards = 12, 5, 15, 0.4
+				w, err := Generate(opt)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var b bytes.Buffer
+				err = WriteInput(w, &b)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if r := solveAndRun(t, b.String()); !r.Valid {
+					t.Errorf("invalid plan: %v", r.Errors)
+				}
+			})
+		}
+	}
+}
+
+func TestSolveRun(t *testing.T) {
+	for _, dir := range []string{"simple", "unusedWildcardTrain", "boardingOnLine"} {
+		w, err := ParseInput(path.Join("..", "test", dir, "input.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Solve(w)
+		if err != nil {
+			t.Fatal(dir, "can not solve:", err)
+		}
+		if r := w.Run(Options{}); !r.Valid {
+			t.Errorf("%s: solved world not valid: %v", dir, r.Errors)
+		}
+	}
+}
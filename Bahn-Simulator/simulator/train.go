@@ -13,12 +13,10 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package simulator
 
 import (
 	"fmt"
-	"math/big"
-	"regexp"
 	"sync"
 )
 
@@ -33,28 +31,27 @@ const (
 
 type Train struct {
 	ID               string
-	Capacity         big.Int
-	Passengers       big.Int
-	Speed            big.Rat
+	Capacity         Int
+	Passengers       Int
+	Speed            Rat
 	Position         []string
-	PositionSince    big.Rat
+	PositionSince    Rat
 	PositionType     TrainPosition
-	Plan             map[string]string
+	Plan             []TrainAction
 	BoardingPossible bool
-	L                sync.Mutex
-}
+	// Wildcard is true if the train has no fixed start station ('*') in the input.
+	Wildcard bool
+	L        sync.Mutex
 
-var trainPlanRegexp = regexp.MustCompile(`\A(?P<time>[\d]+) (?P<action>(Start)|(Depart)) (?P<id>[a-zA-Z0-9_]+)[\s]*\z`)
-var trainPlanRegexpTime = trainPlanRegexp.SubexpIndex("time")
-var trainPlanRegexpAction = trainPlanRegexp.SubexpIndex("action")
-var trainPlanRegexpID = trainPlanRegexp.SubexpIndex("id")
+	nextAction int
+}
 
 func (t *Train) IsValidStart(w *World) error {
 	if t.Capacity.Sign() == -1 {
 		return fmt.Errorf("train (%s): capacity must not be negative", t.ID)
 	}
 
-	if t.Speed.Cmp(big.NewRat(0, 1)) != +1 {
+	if t.Speed.Sign() != +1 {
 		return fmt.Errorf("train (%s): speed must be larger than 0", t.ID)
 	}
 	return t.IsValid(w)
@@ -107,6 +104,13 @@ func (t *Train) Update(w *World, e chan error, wg *sync.WaitGroup) {
 	t.L.Lock()
 	defer t.L.Unlock()
 
+	err := t.update(w)
+	if err != nil {
+		e <- newSimulationError(w, EntityTrain, t.ID, err)
+	}
+}
+
+func (t *Train) update(w *World) error {
 	// Update position
 	switch t.PositionType {
 	case TrainPositionStation:
@@ -115,35 +119,30 @@ func (t *Train) Update(w *World, e chan error, wg *sync.WaitGroup) {
 		t.BoardingPossible = false
 		err := t.advanceLinePosition(w)
 		if err != nil {
-			e <- err
-			return
+			return err
 		}
 	case TrainPositionWildcard:
 		t.BoardingPossible = false
 	default:
-		e <- fmt.Errorf("train (%s): unknown position", t.ID)
-		return
+		return fmt.Errorf("train (%s): unknown position", t.ID)
 	}
 
-	plan, ok := t.Plan[w.CurrentTime.String()]
+	action := t.currentAction(w)
 
-	if !ok {
+	if action == nil {
 		// Nothing to do here
-		return
+		return nil
 	}
 
-	err := t.processRule(w, plan)
-	if err != nil {
-		e <- err
-	}
+	return t.processRule(w, action)
 }
 
 func (t *Train) advanceLinePosition(w *World) error {
 	if t.PositionType != TrainPositionLine {
 		return fmt.Errorf("train %s (internal): positionType must be %d  but is %d", t.ID, TrainPositionLine, t.PositionType)
 	}
-	t.PositionSince.Add(&t.PositionSince, big.NewRat(1, 1))
-	distance := big.NewRat(1, 1).Mul(&t.PositionSince, &t.Speed)
+	t.PositionSince.Add(&t.PositionSince, new(Rat).SetInt64(1))
+	distance := new(Rat).SetInt64(1).Mul(&t.PositionSince, &t.Speed)
 	if len(t.Position) != 2 {
 		return fmt.Errorf("train %s (internal): position %v can not be right (length must be 2)", t.ID, t.Position)
 	}
@@ -161,68 +160,67 @@ func (t *Train) advanceLinePosition(w *World) error {
 		t.Position = []string{t.Position[1]}
 		t.PositionType = TrainPositionStation
 		line.L.Lock()
-		line.CurrentCapacity.Sub(&line.CurrentCapacity, big.NewInt(1))
+		line.CurrentCapacity.Sub(&line.CurrentCapacity, NewInt(1))
 		line.L.Unlock()
 		st.L.Lock()
-		st.CurrenTrains.Add(&st.CurrenTrains, big.NewInt(1))
+		st.CurrenTrains.Add(&st.CurrenTrains, NewInt(1))
 		st.L.Unlock()
+		w.markChanged(EntityTrain, t.ID)
+		w.markChanged(EntityLine, line.ID)
+		w.markChanged(EntityStation, st.ID)
+		w.record(EntityTrain, t.ID, Event{Type: EventTrainArrive, Train: t.ID, Line: line.ID, Station: st.ID})
+		w.recordLineCapacity(EntityTrain, t.ID, line, -1)
+		w.recordStationCapacity(EntityTrain, t.ID, st, +1)
 	}
 
 	return nil
 }
 
-func (t *Train) processRule(w *World, rule string) error {
+func (t *Train) processRule(w *World, action *TrainAction) error {
 	if t.PositionType == TrainPositionLine {
 		return fmt.Errorf("train (%s): new plan but train is still on line", t.ID)
 	}
 	if t.PositionType == TrainPositionWildcard {
 		return fmt.Errorf("train (%s): new plan but train is still on '*' (no Start rule)", t.ID)
 	}
-	matches := trainPlanRegexp.FindStringSubmatch(rule)
-	if matches == nil {
-		return fmt.Errorf("train (%s): can not match rule '%s'", t.ID, rule)
-	}
-	switch matches[trainPlanRegexpAction] {
-	case "Start":
+	switch action.Type {
+	case TrainActionStart:
 		return fmt.Errorf("train (%s): at this point (%s) Start is not allowed", t.ID, w.CurrentTime.String())
-	case "Depart":
-		lineID := matches[trainPlanRegexpID]
+	case TrainActionDepart:
+		// All checks are done before the train is moved so that a failed rule does not change the world
+		lineID := action.Target
 		line, ok := w.Lines[lineID]
 		if !ok {
 			return fmt.Errorf("train (%s): unknown target line %s", t.ID, lineID)
 		}
 		currentPosition := t.Position[0]
-		t.PositionType = TrainPositionLine
-		t.Position = []string{line.ID, ""}
-		t.BoardingPossible = false
-		var foundPosition bool
-		for i := range line.End {
-			if line.End[i] == currentPosition {
-				foundPosition = true
-			} else {
-				t.Position[1] = line.End[i]
-			}
-		}
-		if t.Position[1] == "" {
-			return fmt.Errorf("train (%s): target line %s does not have a target station", t.ID, lineID)
-		}
-		if !foundPosition {
+		target, ok := line.OtherEnd(currentPosition)
+		if !ok {
 			return fmt.Errorf("train (%s): target line %s does not connect to current station %s", t.ID, lineID, currentPosition)
 		}
-		line.L.Lock()
-		line.CurrentCapacity.Add(&line.CurrentCapacity, big.NewInt(1))
-		line.L.Unlock()
 		st, ok := w.Stations[currentPosition]
 		if !ok {
 			return fmt.Errorf("train %s: depature from non existing station %s", t.ID, currentPosition)
 		}
+		t.PositionType = TrainPositionLine
+		t.Position = []string{line.ID, target}
+		t.BoardingPossible = false
+		line.L.Lock()
+		line.CurrentCapacity.Add(&line.CurrentCapacity, NewInt(1))
+		line.L.Unlock()
 		st.L.Lock()
-		st.CurrenTrains.Sub(&st.CurrenTrains, big.NewInt(1))
+		st.CurrenTrains.Sub(&st.CurrenTrains, NewInt(1))
 		st.L.Unlock()
-		t.PositionSince = *big.NewRat(0, 1)
+		w.markChanged(EntityTrain, t.ID)
+		w.markChanged(EntityLine, line.ID)
+		w.markChanged(EntityStation, st.ID)
+		w.record(EntityTrain, t.ID, Event{Type: EventTrainDepart, Train: t.ID, Line: line.ID, Station: st.ID})
+		w.recordLineCapacity(EntityTrain, t.ID, line, +1)
+		w.recordStationCapacity(EntityTrain, t.ID, st, -1)
+		t.PositionSince = Rat{}
 		t.advanceLinePosition(w)
 	default:
-		return fmt.Errorf("train (%s): unknown action '%s'", t.ID, matches[trainPlanRegexpAction])
+		return fmt.Errorf("train (%s): unknown action '%s'", t.ID, action.Type.String())
 	}
 	return nil
 }
@@ -0,0 +1,349 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"fmt"
+	"sync"
+)
+
+type World struct {
+	Lines       map[string]*Line
+	Stations    map[string]*Station
+	Trains      map[string]*Train
+	Passengers  map[string]*Passenger
+	CurrentTime Int
+	MaxTime     Int
+
+	recorder *eventRecorder
+	network  *Network
+	// changes contains the entities changed in the current timestep, see validateChanges
+	changes changeSet
+}
+
+type Line struct {
+	ID              string
+	L               sync.Mutex
+	End             []string
+	Length          Rat
+	MaxCapacity     Int
+	CurrentCapacity Int
+}
+
+type Station struct {
+	ID           string
+	Capacity     Int
+	CurrenTrains Int
+	L            sync.Mutex
+}
+
+// OtherEnd returns the station at the other end of l as seen from station.
+// It returns false if l does not connect to station.
+func (l *Line) OtherEnd(station string) (string, bool) {
+	if len(l.End) != 2 {
+		return "", false
+	}
+	switch station {
+	case l.End[0]:
+		return l.End[1], true
+	case l.End[1]:
+		return l.End[0], true
+	}
+	return "", false
+}
+
+func (l *Line) IsValidStart(w *World) error {
+	if len(l.End) != 2 {
+		return fmt.Errorf("line (%s): ends do not fit (must: 2, is: %d)", l.ID, len(l.End))
+	}
+
+	_, ok := w.Stations[l.End[0]]
+	if !ok {
+		return fmt.Errorf("line (%s): unknown station '%s'", l.ID, l.End[0])
+	}
+
+	_, ok = w.Stations[l.End[1]]
+	if !ok {
+		return fmt.Errorf("line (%s): unknown station '%s'", l.ID, l.End[1])
+	}
+
+	if l.Length.Sign() != +1 {
+		return fmt.Errorf("line (%s): length '%s' must be larger than 0", l.ID, l.Length.String())
+	}
+
+	if l.MaxCapacity.Sign() != +1 {
+		return fmt.Errorf("line (%s): maximum capacity '%s' must be larger than 0", l.ID, l.Length.String())
+	}
+	return l.IsValid(w)
+}
+
+func (l *Line) IsValid(w *World) error {
+	l.L.Lock()
+	defer l.L.Unlock()
+
+	if l.MaxCapacity.Cmp(&l.CurrentCapacity) == -1 {
+		return fmt.Errorf("line (%s): too many trains (capacity: %s, current: %s)", l.ID, l.MaxCapacity.String(), l.CurrentCapacity.String())
+	}
+
+	return nil
+}
+
+func (s *Station) IsValidStart(w *World) error {
+	if s.Capacity.Sign() != +1 {
+		return fmt.Errorf("station (%s): maximum capacity '%s' must be larger than 0", s.ID, s.Capacity.String())
+	}
+	return s.IsValid(w)
+}
+
+func (s *Station) IsValid(w *World) error {
+	if s.Capacity.Cmp(&s.CurrenTrains) == -1 {
+		return fmt.Errorf("station (%s): too many trains (capacity: %s, current: %s)", s.ID, s.Capacity.String(), s.CurrenTrains.String())
+	}
+
+	return nil
+}
+
+func (w *World) Validate() []error {
+	e := make(chan error)
+	var errs []error
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for k := range w.Stations {
+			err := w.validateEntity(EntityStation, k)
+			if err != nil {
+				e <- err
+			}
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for k := range w.Lines {
+			err := w.validateEntity(EntityLine, k)
+			if err != nil {
+				e <- err
+			}
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for k := range w.Trains {
+			err := w.validateEntity(EntityTrain, k)
+			if err != nil {
+				e <- err
+			}
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for k := range w.Passengers {
+			err := w.validateEntity(EntityPassenger, k)
+			if err != nil {
+				e <- err
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(e)
+	}()
+
+	for err := range e {
+		errs = append(errs, err)
+	}
+
+	SortErrors(errs)
+	return errs
+}
+
+// validateEntity validates a single entity of w, see Validate. It returns nil for unknown entities.
+func (w *World) validateEntity(entity Entity, id string) error {
+	var err error
+	switch entity {
+	case EntityStation:
+		if st, ok := w.Stations[id]; ok {
+			err = st.IsValid(w)
+		}
+	case EntityLine:
+		if l, ok := w.Lines[id]; ok {
+			err = l.IsValid(w)
+		}
+	case EntityTrain:
+		if t, ok := w.Trains[id]; ok {
+			err = t.IsValid(w)
+		}
+	case EntityPassenger:
+		if p, ok := w.Passengers[id]; ok {
+			err = p.IsValid(w)
+		}
+	}
+	if err != nil {
+		return newSimulationError(w, entity, id, fmt.Errorf("validation failed for %s '%s': %s", entity.String(), id, err.Error()))
+	}
+	return nil
+}
+
+// entityRef identifies an entity of a world.
+type entityRef struct {
+	entity Entity
+	id     string
+}
+
+// key returns the key of r in Simulation.violating.
+func (r entityRef) key() string {
+	return r.entity.String() + " " + r.id
+}
+
+// changeSet collects the entities changed during a timestep.
+type changeSet struct {
+	l        sync.Mutex
+	entities []entityRef
+}
+
+// markChanged records that the state of an entity changed in the current timestep. It is safe for concurrent use.
+func (w *World) markChanged(entity Entity, id string) {
+	w.changes.l.Lock()
+	w.changes.entities = append(w.changes.entities, entityRef{entity: entity, id: id})
+	w.changes.l.Unlock()
+}
+
+// validateChanges validates all entities changed since the last call like Validate and returns them together
+// with the errors found. Rules only depend on the state of a single entity, so entities which did not change
+// can not become valid or invalid.
+func (w *World) validateChanges() ([]entityRef, []error) {
+	w.changes.l.Lock()
+	changed := w.changes.entities
+	w.changes.entities = nil
+	w.changes.l.Unlock()
+
+	seen := make(map[entityRef]bool, len(changed))
+	checked := changed[:0]
+	var errs []error
+	for _, r := range changed {
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+		checked = append(checked, r)
+		err := w.validateEntity(r.entity, r.id)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	SortErrors(errs)
+	return checked, errs
+}
+
+func (w *World) ValidateStart() []error {
+	var errs []error
+
+	e := make(chan error)
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for k := range w.Stations {
+			err := w.Stations[k].IsValidStart(w)
+			if err != nil {
+				e <- newSimulationError(w, EntityStation, k, fmt.Errorf("validation failed for station '%s': %s", k, err.Error()))
+			}
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for k := range w.Lines {
+			err := w.Lines[k].IsValidStart(w)
+			if err != nil {
+				e <- newSimulationError(w, EntityLine, k, fmt.Errorf("validation failed for line '%s': %s", k, err.Error()))
+			}
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for k := range w.Trains {
+			err := w.Trains[k].IsValidStart(w)
+			if err != nil {
+				e <- newSimulationError(w, EntityTrain, k, fmt.Errorf("validation failed for train '%s': %s", k, err.Error()))
+			}
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for k := range w.Passengers {
+			err := w.Passengers[k].IsValidStart(w)
+			if err != nil {
+				e <- newSimulationError(w, EntityPassenger, k, fmt.Errorf("validation failed for passenger '%s': %s", k, err.Error()))
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(e)
+	}()
+
+	for err := range e {
+		errs = append(errs, err)
+	}
+
+	if !w.CheckConnected() {
+		errs = append(errs, newSimulationError(w, EntityWorld, "", fmt.Errorf("validation failed for world: not all stations are connected")))
+	}
+
+	SortErrors(errs)
+	return errs
+}
+
+// CheckConnected returns whether all stations are connected by lines.
+func (w *World) CheckConnected() bool {
+	if len(w.Stations) == 0 {
+		return true
+	}
+	if len(w.Lines) == 0 {
+		return false
+	}
+	return w.Network().Connected()
+}
+
+// Network returns the network of w. It is built on first use, which is not safe for concurrent use.
+// ParseInput and Run build the network in advance.
+func (w *World) Network() *Network {
+	if w.network == nil {
+		w.network = NewNetwork(w)
+	}
+	return w.network
+}
+
+// UpdateNetwork rebuilds the network of w. It has to be called after stations or lines were changed.
+func (w *World) UpdateNetwork() {
+	w.network = NewNetwork(w)
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bufio"
+	"io"
+)
+
+// WriteInput writes stations, lines, trains and passengers of w in the format of the input file.
+// All sections are written in this order, entities in natural ID order. Comments of the original input are not kept.
+//
+// The initial state is written, so w must not have been simulated.
+func WriteInput(w *World, out io.Writer) error {
+	b := bufio.NewWriter(out)
+
+	b.WriteString("[Stations]\n")
+	ids := make([]string, 0, len(w.Stations))
+	for k := range w.Stations {
+		ids = append(ids, k)
+	}
+	SortIDs(ids)
+	for _, k := range ids {
+		s := w.Stations[k]
+		b.WriteString(s.ID + " " + s.Capacity.String() + "\n")
+	}
+
+	b.WriteString("\n[Lines]\n")
+	ids = ids[:0]
+	for k := range w.Lines {
+		ids = append(ids, k)
+	}
+	SortIDs(ids)
+	for _, k := range ids {
+		l := w.Lines[k]
+		b.WriteString(l.ID + " " + l.End[0] + " " + l.End[1] + " " + l.Length.String() + " " + l.MaxCapacity.String() + "\n")
+	}
+
+	b.WriteString("\n[Trains]\n")
+	ids = ids[:0]
+	for k := range w.Trains {
+		ids = append(ids, k)
+	}
+	SortIDs(ids)
+	for _, k := range ids {
+		t := w.Trains[k]
+		start := "*"
+		if !t.Wildcard {
+			start = t.Position[0]
+		}
+		b.WriteString(t.ID + " " + start + " " + t.Speed.String() + " " + t.Capacity.String() + "\n")
+	}
+
+	b.WriteString("\n[Passengers]\n")
+	ids = ids[:0]
+	for k := range w.Passengers {
+		ids = append(ids, k)
+	}
+	SortIDs(ids)
+	for _, k := range ids {
+		p := w.Passengers[k]
+		b.WriteString(p.ID + " " + p.Start + " " + p.Target + " " + p.Size.String() + " " + p.TargetTime.String() + "\n")
+	}
+	return b.Flush()
+}
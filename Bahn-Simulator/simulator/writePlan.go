@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bufio"
+	"io"
+)
+
+// WritePlan writes the plans of all trains and passengers of w in the format of the output file.
+// Trains are written before passengers, both in natural ID order. Entities without a plan are omitted.
+// The start station of wildcard trains set by a plan is written as 'Start' action, so w must not have been simulated.
+func WritePlan(w *World, out io.Writer) error {
+	return writePlan(w, out, nil)
+}
+
+// writePlan writes the plans of w like WritePlan and places the comments of c in front of their anchors.
+// c may be nil.
+func writePlan(w *World, out io.Writer, c *planComments) error {
+	b := bufio.NewWriter(out)
+	first := true
+	if c.write(b, "") {
+		first = false
+	}
+	section := func(header string) {
+		if !first {
+			b.WriteString("\n")
+		}
+		first = false
+		c.write(b, header)
+		b.WriteString(header + "\n")
+	}
+	action := func(header, time, line string) {
+		c.write(b, header+" "+time)
+		b.WriteString(line + "\n")
+	}
+
+	ids := make([]string, 0, len(w.Trains))
+	for k := range w.Trains {
+		ids = append(ids, k)
+	}
+	SortIDs(ids)
+	for _, k := range ids {
+		t := w.Trains[k]
+		plan := trainPlan(t)
+		if len(plan) == 0 {
+			continue
+		}
+		header := "[Train:" + t.ID + "]"
+		section(header)
+		for i := range plan {
+			action(header, plan[i].Time.String(), plan[i].String())
+		}
+	}
+
+	ids = ids[:0]
+	for k := range w.Passengers {
+		ids = append(ids, k)
+	}
+	SortIDs(ids)
+	for _, k := range ids {
+		p := w.Passengers[k]
+		if len(p.Plan) == 0 {
+			continue
+		}
+		header := "[Passenger:" + p.ID + "]"
+		section(header)
+		for i := range p.Plan {
+			action(header, p.Plan[i].Time.String(), p.Plan[i].String())
+		}
+	}
+
+	// Comments without an anchor in the written plan
+	if c.remaining() && !first {
+		b.WriteString("\n")
+	}
+	c.writeRemaining(b)
+	return b.Flush()
+}
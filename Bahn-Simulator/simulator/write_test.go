@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parseWorld parses input and plan.
+func parseWorld(t *testing.T, input, plan []byte) *World {
+	t.Helper()
+	w, err := ParseInputReader(bytes.NewReader(input))
+	if err != nil {
+		t.Fatal("can not parse input:", err)
+	}
+	err = ParsePlanReader(w, bytes.NewReader(plan))
+	if err != nil {
+		t.Fatal("can not parse plan:", err)
+	}
+	return w
+}
+
+// writeWorld parses input and plan and writes both back.
+func writeWorld(t *testing.T, input, plan []byte) ([]byte, []byte, Result) {
+	t.Helper()
+	w := parseWorld(t, input, plan)
+	var in, out bytes.Buffer
+	err := WriteInput(w, &in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = WritePlan(w, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return in.Bytes(), out.Bytes(), w.Run(Options{})
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	dirs, err := os.ReadDir(path.Join("..", "test"))
+	if err != nil {
+		t.Fatal("can not read test dir:", err)
+	}
+	for i := range dirs {
+		if !dirs[i].IsDir() || skipLarge(dirs[i].Name()) {
+			continue
+		}
+		t.Run(dirs[i].Name(), func(t *testing.T) {
+			input, err := os.ReadFile(path.Join("..", "test", dirs[i].Name(), "input.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			plan, err := os.ReadFile(path.Join("..", "test", dirs[i].Name(), "output.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			in, out, r := writeWorld(t, input, plan)
+			compareWorlds(t, parseWorld(t, input, plan), parseWorld(t, in, out))
+			in2, out2, r2 := writeWorld(t, in, out)
+			if !bytes.Equal(in, in2) {
+				t.Errorf("input not stable:\n%s\n%s", in, in2)
+			}
+			if !bytes.Equal(out, out2) {
+				t.Errorf("plan not stable:\n%s\n%s", out, out2)
+			}
+			if r.Valid != r2.Valid || r.Delay.Cmp(r2.Delay) != 0 {
+				t.Errorf("different result: %v %s, %v %s", r.Valid, r.Delay, r2.Valid, r2.Delay)
+			}
+		})
+	}
+}
+
+// compareWorlds reports all differences between the initial states and plans of a and b.
+// MaxTime is not compared, as ParsePlan calculates it depending on the order of actions.
+func compareWorlds(t *testing.T, a, b *World) {
+	t.Helper()
+	if len(a.Stations) != len(b.Stations) || len(a.Lines) != len(b.Lines) || len(a.Trains) != len(b.Trains) || len(a.Passengers) != len(b.Passengers) {
+		t.Fatalf("different number of entities: %d/%d/%d/%d, %d/%d/%d/%d", len(a.Stations), len(a.Lines), len(a.Trains), len(a.Passengers), len(b.Stations), len(b.Lines), len(b.Trains), len(b.Passengers))
+	}
+	for k, s := range a.Stations {
+		o, ok := b.Stations[k]
+		if !ok || s.Capacity.Cmp(&o.Capacity) != 0 || s.CurrenTrains.Cmp(&o.CurrenTrains) != 0 {
+			t.Errorf("station %s differs", k)
+		}
+	}
+	for k, l := range a.Lines {
+		o, ok := b.Lines[k]
+		if !ok || !reflect.DeepEqual(l.End, o.End) || l.Length.Cmp(&o.Length) != 0 || l.MaxCapacity.Cmp(&o.MaxCapacity) != 0 {
+			t.Errorf("line %s differs", k)
+		}
+	}
+	for k, tr := range a.Trains {
+		o, ok := b.Trains[k]
+		if !ok || tr.Capacity.Cmp(&o.Capacity) != 0 || tr.Speed.Cmp(&o.Speed) != 0 || tr.Wildcard != o.Wildcard ||
+			tr.PositionType != o.PositionType || !reflect.DeepEqual(tr.Position, o.Position) {
+			t.Errorf("train %s differs", k)
+			continue
+		}
+		if len(tr.Plan) != len(o.Plan) {
+			t.Errorf("plan of train %s differs: %v, %v", k, tr.Plan, o.Plan)
+			continue
+		}
+		for i := range tr.Plan {
+			if tr.Plan[i].String() != o.Plan[i].String() {
+				t.Errorf("plan of train %s differs: %s, %s", k, tr.Plan[i].String(), o.Plan[i].String())
+			}
+		}
+	}
+	for k, p := range a.Passengers {
+		o, ok := b.Passengers[k]
+		if !ok || p.Start != o.Start || p.Target != o.Target || p.Size.Cmp(&o.Size) != 0 || p.TargetTime.Cmp(&o.TargetTime) != 0 {
+			t.Errorf("passenger %s differs", k)
+			continue
+		}
+		if len(p.Plan) != len(o.Plan) {
+			t.Errorf("plan of passenger %s differs: %v, %v", k, p.Plan, o.Plan)
+			continue
+		}
+		for i := range p.Plan {
+			if p.Plan[i].String() != o.Plan[i].String() {
+				t.Errorf("plan of passenger %s differs: %s, %s", k, p.Plan[i].String(), o.Plan[i].String())
+			}
+		}
+	}
+}
+
+func TestWriteCanonical(t *testing.T) {
+	input := "[Stations]\nS10 1\nS2 2\n[Lines]\nL1 S2 S10 1.50 1\n[Trains]\nT1 * 0.9999999 5\n[Passengers]\nP1 S2 S10 1 3\n"
+	plan := "[Passenger:P1]\n3 Detrain\n1 Board T1\n[Train:T1]\n0 Start S2\n2 Depart L1\n"
+	in, out, _ := writeWorld(t, []byte(input), []byte(plan))
+	wantIn := "[Stations]\nS2 2\nS10 1\n\n[Lines]\nL1 S2 S10 1.5 1\n\n[Trains]\nT1 * 0.9999999 5\n\n[Passengers]\nP1 S2 S10 1 3\n"
+	wantOut := "[Train:T1]\n0 Start S2\n2 Depart L1\n\n[Passenger:P1]\n1 Board T1\n3 Detrain\n"
+	if string(in) != wantIn {
+		t.Errorf("wrong input:\n%s", in)
+	}
+	if string(out) != wantOut {
+		t.Errorf("wrong plan:\n%s", out)
+	}
+}
+
+func TestFormatPlan(t *testing.T) {
+	input := "[Stations]\nS1 2\nS2 2\n[Lines]\nL1 S1 S2 1 1\n[Trains]\nT2 S1 1 5\nT10 * 1 5\n[Passengers]\nP1 S1 S2 1 3\n"
+	plan := "# Plan\n\n[Passenger:P1]   \n# Arrival\n3 Detrain  \n1 Board T2\n\n\n# Fast train\n[Train:T10]\n0 Start S2\n[Train:T2]\n# First\n2 Depart L1\n# End\n"
+	want := "# Plan\n\n[Train:T2]\n# First\n2 Depart L1\n\n# Fast train\n[Train:T10]\n0 Start S2\n\n[Passenger:P1]\n1 Board T2\n# Arrival\n3 Detrain\n\n# End\n"
+
+	format := func(plan string) string {
+		w, err := ParseInputReader(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		err = FormatPlan(w, strings.NewReader(plan), &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b.String()
+	}
+	got := format(plan)
+	if got != want {
+		t.Errorf("wrong format:\n%s", got)
+	}
+	if again := format(got); again != got {
+		t.Errorf("format not stable:\n%s", again)
+	}
+}
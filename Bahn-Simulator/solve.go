@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/informatiCup/informatiCup2022/Bahn-Simulator/simulator"
+)
+
+func solveCommand(args []string) int {
+	fs := flag.NewFlagSet("solve", flag.ExitOnError)
+	inputPath := fs.String("input", "input.txt", "path to input file ('-' reads from stdin)")
+	outputPath := fs.String("output", "output.txt", "path the plan is written to ('-' writes to stdout)")
+	fs.Parse(args)
+
+	var input []byte
+	var err error
+	if *inputPath == "-" {
+		input, err = io.ReadAll(os.Stdin)
+	} else {
+		input, err = os.ReadFile(*inputPath)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can not read input file:", err)
+		return 2
+	}
+
+	plan, result, err := solve(input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !result.Valid {
+		fmt.Fprintln(os.Stderr, "solver created an invalid plan:")
+		for i := range result.Errors {
+			fmt.Fprintln(os.Stderr, result.Errors[i].Error())
+		}
+		return 1
+	}
+
+	// Keep stdout free for the plan if it is written there
+	score := os.Stdout
+	if *outputPath == "-" {
+		_, err = os.Stdout.Write(plan)
+		score = os.Stderr
+	} else {
+		err = os.WriteFile(*outputPath, plan, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Can not write plan:", err)
+		return 2
+	}
+	fmt.Fprintln(score, result.Delay.String())
+	return 0
+}
+
+// solve creates a plan for input. The plan is verified by simulating it on a freshly parsed world.
+func solve(input []byte) ([]byte, simulator.Result, error) {
+	world, err := simulator.ParseInputReader(bytes.NewReader(input))
+	if err != nil {
+		return nil, simulator.Result{}, err
+	}
+	err = simulator.Solve(world)
+	if err != nil {
+		return nil, simulator.Result{}, fmt.Errorf("can not solve: %w", err)
+	}
+	var plan bytes.Buffer
+	err = simulator.WritePlan(world, &plan)
+	if err != nil {
+		return nil, simulator.Result{}, err
+	}
+
+	world, err = simulator.ParseInputReader(bytes.NewReader(input))
+	if err != nil {
+		return nil, simulator.Result{}, err
+	}
+	err = simulator.ParsePlanReader(world, bytes.NewReader(plan.Bytes()))
+	if err != nil {
+		return nil, simulator.Result{}, fmt.Errorf("can not read created plan: %w", err)
+	}
+	return plan.Bytes(), world.Run(simulator.Options{}), nil
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright 2021 Marcus Soll
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//        http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/informatiCup/informatiCup2022/Bahn-Simulator/simulator"
+)
+
+type jsonEvent struct {
+	Time      *simulator.Int `json:"time"`
+	Type      string         `json:"type"`
+	Train     string         `json:"train,omitempty"`
+	Passenger string         `json:"passenger,omitempty"`
+	Line      string         `json:"line,omitempty"`
+	Station   string         `json:"station,omitempty"`
+	Count     *simulator.Int `json:"count,omitempty"`
+	Capacity  *simulator.Int `json:"capacity,omitempty"`
+}
+
+var traceCSVHeader = []string{"time", "type", "train", "passenger", "line", "station", "count", "capacity"}
+
+// traceWriter writes events as JSON Lines or CSV.
+// The first write error is kept and returned by Close; later events are dropped.
+type traceWriter struct {
+	json *json.Encoder
+	csv  *csv.Writer
+	err  error
+}
+
+func newTraceWriter(out io.Writer, format string) (*traceWriter, error) {
+	switch format {
+	case "jsonl":
+		return &traceWriter{json: json.NewEncoder(out)}, nil
+	case "csv":
+		t := &traceWriter{csv: csv.NewWriter(out)}
+		t.err = t.csv.Write(traceCSVHeader)
+		return t, nil
+	default:
+		return nil, fmt.Errorf("unknown trace format '%s'", format)
+	}
+}
+
+func (t *traceWriter) Write(e simulator.Event) {
+	if t.err != nil {
+		return
+	}
+	capacityEvent := e.Type == simulator.EventLineCapacity || e.Type == simulator.EventStationCapacity
+
+	if t.json != nil {
+		j := jsonEvent{
+			Time:      new(simulator.Int).Set(&e.Time),
+			Type:      e.Type.String(),
+			Train:     e.Train,
+			Passenger: e.Passenger,
+			Line:      e.Line,
+			Station:   e.Station,
+		}
+		if capacityEvent {
+			j.Count = new(simulator.Int).Set(&e.Count)
+			j.Capacity = new(simulator.Int).Set(&e.Capacity)
+		}
+		t.err = t.json.Encode(j)
+		return
+	}
+
+	var count, capacity string
+	if capacityEvent {
+		count, capacity = e.Count.String(), e.Capacity.String()
+	}
+	t.err = t.csv.Write([]string{e.Time.String(), e.Type.String(), e.Train, e.Passenger, e.Line, e.Station, count, capacity})
+}
+
+func (t *traceWriter) Close() error {
+	if t.csv != nil {
+		t.csv.Flush()
+		if t.err == nil {
+			t.err = t.csv.Error()
+		}
+	}
+	return t.err
+}